@@ -2,8 +2,11 @@ package commonmark
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 	"log"
 	"regexp"
+	"strconv"
 )
 
 // Block represents a node in the parse tree.
@@ -22,6 +25,9 @@ type Block interface {
 	// ReplaceLastChild replaces the last child block with the given one.
 	ReplaceLastChild(Block)
 
+	// RemoveLastChild removes the last child block.
+	RemoveLastChild()
+
 	// AppendLine appends the given line to the list of lines.
 	AppendLine([]byte)
 
@@ -34,6 +40,13 @@ type Block interface {
 
 	// CanContain returns whether this block can contain the given block.
 	CanContain(Block) bool
+
+	// Close is called when the block is closed, i.e. when no more lines or
+	// children will be added to it.
+	Close()
+
+	// base returns the common part of the block.
+	base() *block
 }
 
 // block implements the common part of the Block interface.
@@ -41,6 +54,65 @@ type block struct {
 	children      []Block
 	content       []byte
 	inlineContent Inline
+
+	// startLine and endLine are the 1-based numbers of the first and last
+	// line of the input that the block spans.
+	startLine, endLine int
+	// startColumn is the 0-based column in the start line at which the block
+	// starts, after tab expansion.
+	startColumn int
+	// contentLines records where each line of the content came from, so that
+	// positions in the content can be mapped back to the input.
+	contentLines []contentLine
+}
+
+// contentLine records the origin of a line of block content.
+type contentLine struct {
+	// offset is the offset of the line in the content.
+	offset int
+	// line is the 1-based line number.
+	line int
+	// column is the 0-based column in that line, after tab expansion.
+	column int
+}
+
+// trimContentStart removes the first n bytes of the content. The block then
+// starts at the first remaining byte.
+func (b *block) trimContentStart(n int) {
+	b.content = b.content[n:]
+	lines := b.contentLines[:0]
+	for i, l := range b.contentLines {
+		if i+1 < len(b.contentLines) && b.contentLines[i+1].offset <= n {
+			// The line was removed entirely.
+			continue
+		}
+		if l.offset < n {
+			l.column += n - l.offset
+			l.offset = 0
+		} else {
+			l.offset -= n
+		}
+		lines = append(lines, l)
+	}
+	b.contentLines = lines
+	if len(lines) > 0 {
+		b.startLine, b.startColumn = lines[0].line, lines[0].column
+	}
+}
+
+// contentPosition returns the line number and 0-based column, after tab
+// expansion, of the given offset in the content.
+func (b *block) contentPosition(offset int) (line, column int) {
+	for i := len(b.contentLines) - 1; i >= 0; i-- {
+		if l := b.contentLines[i]; l.offset <= offset {
+			return l.line, l.column + offset - l.offset
+		}
+	}
+	return b.startLine, b.startColumn + offset
+}
+
+func (b *block) base() *block {
+	return b
 }
 
 func (b *block) Children() []Block {
@@ -55,6 +127,10 @@ func (b *block) ReplaceLastChild(child Block) {
 	b.children[len(b.children)-1] = child
 }
 
+func (b *block) RemoveLastChild() {
+	b.children = b.children[:len(b.children)-1]
+}
+
 func (b *block) AppendLine(line []byte) {
 	b.content = append(b.content, line...)
 }
@@ -71,13 +147,23 @@ func (b *block) CanContain(Block) bool {
 	return false
 }
 
+func (b *block) Close() {
+}
+
 // document is the root node of the parse tree.
 type document struct {
 	block
+	references referenceMap
+	footnotes  footnoteMap
+	sourceMap  sourceMap
+	// lineEnding is the first line ending in the input, or empty if there is
+	// none.
+	lineEnding string
 }
 
-func (d *document) CanContain(Block) bool {
-	return true
+func (d *document) CanContain(b Block) bool {
+	_, isListItem := b.(*listItem)
+	return !isListItem
 }
 
 // horizontalRule is a horizontal rule.
@@ -97,6 +183,8 @@ type horizontalRule struct {
 type atxHeader struct {
 	block
 	level int
+	// id is the id assigned to the header with Options.HeadingIDs, if any.
+	id string
 }
 
 // indentedCodeBlock represents an indented code block.
@@ -116,6 +204,78 @@ func (c *indentedCodeBlock) AcceptsLiteralLines() bool {
 	return true
 }
 
+func (c *indentedCodeBlock) Close() {
+	// "Blank lines preceding or following an indented code block are not
+	// included in it."
+	end := len(c.content)
+	for end > 0 {
+		lineStart := bytes.LastIndexByte(c.content[:end-1], '\n') + 1
+		if !isBlank(c.content[lineStart:end]) {
+			break
+		}
+		end = lineStart
+		c.endLine--
+	}
+	c.content = c.content[:end]
+}
+
+// fencedCodeBlock represents a fenced code block.
+//
+// "A code fence is a sequence of at least three consecutive backtick
+// characters (`) or tildes (~). (Tildes and backticks cannot be mixed.) A
+// fenced code block begins with a code fence, indented no more than three
+// spaces."
+type fencedCodeBlock struct {
+	block
+	fenceChar   byte
+	fenceLength int
+	fenceIndent int
+	info        []byte
+	// ended is true if the block was ended by a closing code fence, rather
+	// than by the end of its container.
+	ended bool
+}
+
+func (c *fencedCodeBlock) AcceptsLines() bool {
+	return true
+}
+
+func (c *fencedCodeBlock) AcceptsLiteralLines() bool {
+	return true
+}
+
+func (c *fencedCodeBlock) AppendLine(line []byte) {
+	// "If the leading code fence is indented N spaces, then up to N spaces of
+	// indentation are removed from each line of the content (if present)."
+	indent := indentation(line)
+	if indent > c.fenceIndent {
+		indent = c.fenceIndent
+	}
+	c.block.AppendLine(line[indent:])
+}
+
+// htmlBlock represents a block of raw HTML.
+//
+// "An HTML block is a group of lines that is treated as raw HTML (and will not
+// be escaped in HTML output)."
+type htmlBlock struct {
+	block
+	// kind is the number (1-7) of the start condition that started the
+	// block, which determines its end condition.
+	kind int
+	// ended is true if the block was ended by its end condition, rather than
+	// by the end of its container.
+	ended bool
+}
+
+func (h *htmlBlock) AcceptsLines() bool {
+	return true
+}
+
+func (h *htmlBlock) AcceptsLiteralLines() bool {
+	return true
+}
+
 // paragraph represents a paragraph of text.
 //
 // "A sequence of non-blank lines that cannot be interpreted as other kinds of
@@ -138,19 +298,159 @@ type blockQuote struct {
 	block
 }
 
-func (q *blockQuote) CanContain(Block) bool {
-	return true
+func (q *blockQuote) CanContain(b Block) bool {
+	_, isListItem := b.(*listItem)
+	return !isListItem
+}
+
+// listBlock represents a list, which is a sequence of list items of the same type.
+//
+// "A list is a sequence of one or more list items of the same type. Changing
+// the bullet or ordered list delimiter starts a new list."
+type listBlock struct {
+	block
+	listMarker
+	// tight is true if the list items are not separated by blank lines.
+	tight bool
+}
+
+func (l *listBlock) CanContain(b Block) bool {
+	_, isListItem := b.(*listItem)
+	return isListItem
+}
+
+func (l *listBlock) Close() {
+	// As for list items, blank lines after the last item do not count as part
+	// of the list. Otherwise, a blank line at the end of a sublist would not
+	// separate it from what follows it in the parent list.
+	if len(l.children) > 0 {
+		l.endLine = l.children[len(l.children)-1].base().endLine
+	}
+
+	// "A list is loose if any of its constituent list items are separated by
+	// blank lines, or if any of its constituent list items directly contain
+	// two block-level elements with a blank line between them. Otherwise a
+	// list is tight."
+	l.tight = true
+	for i, item := range l.children {
+		if i+1 < len(l.children) && endsWithBlankLine(item, l.children[i+1]) {
+			l.tight = false
+			return
+		}
+		children := item.Children()
+		for j := 0; j+1 < len(children); j++ {
+			if endsWithBlankLine(children[j], children[j+1]) {
+				l.tight = false
+				return
+			}
+		}
+	}
+}
+
+// endsWithBlankLine returns whether there is a blank line between the given
+// block and its next sibling.
+func endsWithBlankLine(b, next Block) bool {
+	return next.base().startLine > b.base().endLine+1
+}
+
+// listItem represents a single item in a list.
+type listItem struct {
+	block
+	listMarker
+	// task is true if the item starts with a task list item marker, with the
+	// task lists extension. checked is true if the marker is checked.
+	task, checked bool
+}
+
+func (i *listItem) CanContain(b Block) bool {
+	_, isListItem := b.(*listItem)
+	return !isListItem
+}
+
+func (i *listItem) Close() {
+	// Any blank lines after the content of the item do not count as part of
+	// it.
+	if len(i.children) > 0 {
+		i.endLine = i.children[len(i.children)-1].base().endLine
+	} else {
+		i.endLine = i.startLine
+	}
+}
+
+var taskListMarkerRe = regexp.MustCompile(`^\[([ xX])\]([ ]+|\n)`)
+
+// parseTaskListMarker checks whether the item is a task list item, and if so,
+// removes the marker from its first paragraph.
+//
+// "A task list item marker consists of optional spaces or tabs followed by a
+// left bracket ([), either a whitespace character or the letter x in either
+// lowercase or uppercase, and then a right bracket (]). When present, it must
+// be the first item in the list item's paragraph, followed by whitespace."
+func (i *listItem) parseTaskListMarker() {
+	if len(i.children) == 0 {
+		return
+	}
+	par, ok := i.children[0].(*paragraph)
+	if !ok {
+		return
+	}
+	m := taskListMarkerRe.FindSubmatch(par.content)
+	if m == nil {
+		return
+	}
+	i.task = true
+	i.checked = m[1][0] != ' '
+	// A newline after the marker is part of the paragraph.
+	par.trimContentStart(len(m[0]) - bytes.Count(m[2], []byte{'\n'}))
+}
+
+// listMarker describes the marker that starts a list item.
+type listMarker struct {
+	// bulletChar is the bullet character of a bullet list item, or 0 for an
+	// ordered list item.
+	bulletChar byte
+	// delimiter is the '.' or ')' following the number of an ordered list
+	// item, or 0 for a bullet list item.
+	delimiter byte
+	// start is the number of an ordered list item.
+	start int
+	// markerOffset is the indentation of the list marker.
+	markerOffset int
+	// padding is the width of the list marker plus the spaces following it;
+	// continuation lines must be indented by markerOffset+padding.
+	padding int
+}
+
+// matches returns whether a list item with this marker can be part of a list
+// that started with the other marker.
+func (m *listMarker) matches(other *listMarker) bool {
+	return m.bulletChar == other.bulletChar && m.delimiter == other.delimiter
+}
+
+// ordered returns whether the marker is that of an ordered list item.
+func (m *listMarker) ordered() bool {
+	return m.delimiter != 0
 }
 
 // parseBlocks performs the first parsing pass: turning the document into a
 // tree of blocks. Inline content is not parsed at this time.
-func parseBlocks(data []byte) (*document, error) {
-	doc := &document{}
+//
+// If the input is available in memory, it is passed as input too, and r must
+// read from it. Otherwise, input is nil.
+func parseBlocks(r io.Reader, input []byte, options *Options) (*document, error) {
+	doc := &document{
+		block:      block{startLine: 1},
+		references: make(referenceMap),
+		footnotes:  footnoteMap{definitions: make(map[string]*footnoteDefinition)},
+		sourceMap:  sourceMap{tabStop: options.tabStop()},
+	}
 	parser := blockParser{
 		doc:        doc,
 		openBlocks: []Block{doc},
+		options:    options,
+		input:      input,
 	}
-	if err := parser.parse(data); err != nil {
+	if err := parser.parse(r); err != nil {
 		return nil, err
 	}
 	return doc, nil
@@ -159,13 +459,43 @@ func parseBlocks(data []byte) (*document, error) {
 type blockParser struct {
 	doc        *document
 	openBlocks []Block
+	options    *Options
+	// input is the entire input, if it is available in memory.
+	input []byte
+	// lineNumber is the 1-based number of the current line.
+	lineNumber int
+	// line is the current line, after tab expansion.
+	line []byte
+	// lastMatched is the index in openBlocks of the last block that the
+	// current line continues. Blocks after it are closed as soon as a new
+	// block is started or the line turns out not to be a lazy continuation
+	// line.
+	lastMatched int
+	// err is the first error found in strict mode.
+	err *ParseError
+}
+
+// closeUnmatchedBlocks closes all open blocks that the current line does not
+// continue.
+func (p *blockParser) closeUnmatchedBlocks() {
+	for len(p.openBlocks) > p.lastMatched+1 {
+		p.closeLastBlock()
+	}
 }
 
-func (p *blockParser) addChild(child Block) {
+// addChild adds a new block as a child of the last matched block (or of its
+// closest ancestor that can contain it), and makes it the last matched block.
+// The block starts at the given column of the current line.
+func (p *blockParser) addChild(child Block, column int) {
+	p.closeUnmatchedBlocks()
 	for i := len(p.openBlocks) - 1; i >= 0; i-- {
 		if p.openBlocks[i].CanContain(child) {
+			child.base().startLine = p.lineNumber
+			child.base().endLine = p.lineNumber
+			child.base().startColumn = column
 			p.openBlocks[i].AppendChild(child)
 			p.openBlocks = append(p.openBlocks, child)
+			p.lastMatched = len(p.openBlocks) - 1
 			return
 		} else {
 			p.closeLastBlock()
@@ -174,7 +504,102 @@ func (p *blockParser) addChild(child Block) {
 }
 
 func (p *blockParser) closeLastBlock() {
+	b := p.openBlock()
+	b.Close()
 	p.openBlocks = p.openBlocks[:len(p.openBlocks)-1]
+
+	if p.options.Strict {
+		p.checkEnded(b)
+	}
+
+	if par, ok := b.(*paragraph); ok {
+		p.extractReferenceDefinitions(par)
+		// "If there are several matching definitions, the first one takes
+		// precedence." But they are all removed from the paragraph, which is
+		// dropped entirely if nothing else remains.
+		if isBlank(par.content) {
+			p.openBlock().RemoveLastChild()
+		}
+	}
+}
+
+// checkEnded records an error if the block, which has just been closed, is a
+// fenced code block or HTML block that was not ended explicitly. The spec
+// allows these to run until the end of their container, but it is usually a
+// mistake.
+//
+// "If the end of the containing block (or document) is reached and no closing
+// code fence has been found, the code block contains all of the lines after
+// the opening code fence until the end of the containing block (or
+// document)."
+func (p *blockParser) checkEnded(b Block) {
+	switch t := b.(type) {
+	case *fencedCodeBlock:
+		if !t.ended {
+			p.errorf(b, "code fence %q is never closed", bytes.Repeat([]byte{t.fenceChar}, t.fenceLength))
+		}
+	case *htmlBlock:
+		// Blocks of kinds 6 and 7 are ended by a blank line, but may just as
+		// well end with their container.
+		if !t.ended && t.kind <= 5 {
+			p.errorf(b, "HTML block is never ended")
+		}
+	}
+}
+
+// errorf records an error at the start of the given block, unless an error
+// has been recorded already.
+func (p *blockParser) errorf(b Block, format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	pos := p.doc.sourceMap.position(b.base().startLine, b.base().startColumn)
+	p.err = &ParseError{Line: pos.Line, Col: pos.Column, Msg: fmt.Sprintf(format, args...)}
+}
+
+// extractReferenceDefinitions removes any link reference definitions from the
+// start of the paragraph, and adds them to the document's reference map.
+//
+// "A link reference definition consists of a link label, indented up to three
+// spaces, followed by a colon (:), optional whitespace (including up to one
+// line ending), a link destination, optional whitespace (including up to one
+// line ending), and an optional link title [...]. It defines a label which can
+// be used in reference links and reference-style images elsewhere in the
+// document."
+func (p *blockParser) extractReferenceDefinitions(par *paragraph) {
+	for {
+		label, ref, length := parseReferenceDefinition(par.content)
+		if length == 0 {
+			return
+		}
+		key := normalizeLabel(label)
+		if _, ok := p.doc.references[key]; !ok {
+			p.doc.references[key] = ref
+		}
+		par.trimContentStart(length)
+	}
+}
+
+// appendLine appends a line, which is (part of) the current line, to the
+// content of the given block.
+func (p *blockParser) appendLine(b Block, line []byte) {
+	before := len(b.base().content)
+	b.AppendLine(line)
+	// The block may have dropped some characters from the start of the line.
+	appended := len(b.base().content) - before
+	b.base().contentLines = append(b.base().contentLines, contentLine{
+		offset: before,
+		line:   p.lineNumber,
+		column: p.column(line) + len(line) - appended,
+	})
+}
+
+// column returns the 0-based column at which the given slice of the current
+// line starts.
+func (p *blockParser) column(slice []byte) int {
+	// All slices of the current line share its backing array, so their
+	// capacities differ by their offset.
+	return cap(p.line) - cap(slice)
 }
 
 func (p *blockParser) openBlock() Block {
@@ -187,114 +612,295 @@ func (p *blockParser) replaceOpenBlock(b Block) {
 	p.openBlocks[len(p.openBlocks)-1] = b
 }
 
-func (p *blockParser) parse(data []byte) error {
+func (p *blockParser) parse(r io.Reader) error {
 	// See:
 	// http://spec.commonmark.org/0.7/#how-source-lines-alter-the-document-tree
-	scanner := newScanner(data)
+	scanner := newScanner(r)
 	for scanner.Scan() {
-		line := scanner.Bytes()
-		line = tabsToSpaces(line)
-		line = append(line, '\n')
-
-		// "The line is analyzed and, depending on its contents, the document
-		// may be altered in one or more of the following ways:"
-
-		// "1. One or more open blocks may be closed."
-		var openBlock Block
-		var i int
-		for i, openBlock = range p.openBlocks {
-			indent := indentation(line)
-			blank := line[indent] == '\n'
-
-			allMatched := true
-			switch openBlock.(type) {
-			case *indentedCodeBlock:
-				if indent >= 4 || blank {
-					if len(line) > 4 {
-						line = line[4:]
-					} else {
-						line = line[len(line)-1:]
-					}
-				} else {
-					allMatched = false
-				}
-			case *paragraph:
-				if blank {
-					allMatched = false
-				}
-			case *blockQuote:
-				// "3. Consecutiveness. A document cannot contain two block
-				// quotes in a row unless there is a blank line between them."
-				if blank {
-					allMatched = false
-				} else if line[indent] == '>' {
-					line = stripBlockQuoteMarker(line)
-				}
-			}
-			if !allMatched {
-				assertf(i > 0, "allMatched should not become false at the document root")
-				i--
-				break
-			}
+		rawLine := scanner.Bytes()
+		line := rawLine
+		changed := bytes.IndexAny(rawLine, "\t\x00") >= 0
+		if changed {
+			line = replaceNULs(tabsToSpaces(rawLine, p.options.tabStop()))
 		}
+		p.doc.sourceMap.addLine(rawLine, scanner.lineOffset, changed)
+		if end := scanner.lineOffset + len(rawLine); !changed && end < len(p.input) && p.input[end] == '\n' {
+			// The line is unchanged by preprocessing and ends in a newline in
+			// the input, so it can be used in place.
+			line = p.input[scanner.lineOffset : end+1]
+		} else {
+			// The scanner may reuse its buffer, and blocks may hold on to
+			// parts of the line, so make sure that appending the newline
+			// results in a copy.
+			line = append(line[:len(line):len(line)], '\n')
+		}
+		p.lineNumber++
+		p.line = line
+		p.parseLine(line)
+		for _, b := range p.openBlocks {
+			b.base().endLine = p.lineNumber
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	p.doc.lineEnding = scanner.firstLineEnding
+	for len(p.openBlocks) > 0 {
+		p.closeLastBlock()
+	}
+	if p.err != nil {
+		return p.err
+	}
+	return nil
+}
 
-		for len(p.openBlocks) > i+1 {
+// parseLine incorporates a single line, ending in a newline character, into
+// the document tree.
+func (p *blockParser) parseLine(line []byte) {
+	// "The line is analyzed and, depending on its contents, the document may
+	// be altered in one or more of the following ways:"
+
+	// "1. One or more open blocks may be closed."
+	// First, find the last open block that this line continues. The blocks
+	// after it are not closed yet, because the line might turn out to be a
+	// lazy continuation line.
+	p.lastMatched = 0
+	for p.lastMatched+1 < len(p.openBlocks) {
+		rest, matched := continueBlock(p.openBlocks[p.lastMatched+1], line)
+		if !matched {
+			break
+		}
+		p.lastMatched++
+		if rest == nil {
+			// The line was consumed entirely, and ends the block.
+			p.closeUnmatchedBlocks()
+			p.openBlock().base().endLine = p.lineNumber
 			p.closeLastBlock()
+			return
+		}
+		line = rest
+	}
+
+	// "2. One or more new blocks may be created as children of the last open
+	// block."
+	for !p.openBlocks[p.lastMatched].AcceptsLiteralLines() {
+		if p.lastMatched >= p.options.maxNestingDepth() {
+			// No more blocks can be nested here, so the rest of the line is
+			// paragraph text.
+			break
 		}
+		container := p.openBlocks[p.lastMatched]
+		par, containerIsParagraph := container.(*paragraph)
+		indent := indentation(line)
 
-		// "2. One or more new blocks may be created as children of the last open block."
-		for !p.openBlock().AcceptsLiteralLines() {
-			openBlock := p.openBlock()
-			par, isParagraph := openBlock.(*paragraph)
-			if !isParagraph && indentation(line) >= 4 {
-				p.addChild(&indentedCodeBlock{})
+		if indent >= 4 {
+			if !isBlank(line) && p.canStart(&indentedCodeBlock{}, line) {
+				p.addChild(&indentedCodeBlock{}, p.column(line))
 				line = line[4:]
-			} else if line[indentation(line)] == '>' {
-				p.addChild(&blockQuote{})
-				line = stripBlockQuoteMarker(line)
-			} else if level, content := parseATXHeader(line); level > 0 {
-				p.addChild(&atxHeader{level: level, block: block{content: content}})
-				p.closeLastBlock()
-				line = nil
-				break
-			} else if level := parseSetextUnderline(line); isParagraph && level > 0 && hasOneLine(par.content) {
-				p.replaceOpenBlock(&atxHeader{level: level, block: block{content: par.content}})
-				p.closeLastBlock()
-				line = nil
-				break
-			} else if isHorizontalRule(line) {
-				p.addChild(&horizontalRule{})
+			}
+			break
+		} else if line[indent] == '>' {
+			p.addChild(&blockQuote{}, p.column(line)+indent)
+			line = stripBlockQuoteMarker(line)
+		} else if f, rest := parseFootnoteDefinitionStart(line); p.options.Footnotes && f != nil {
+			p.addChild(f, p.column(line)+indent)
+			p.doc.footnotes.define(f)
+			line = rest
+		} else if fence := parseCodeFence(line); fence != nil {
+			p.addChild(fence, p.column(line)+indent)
+			return
+		} else if kind := parseHTMLBlockStart(line); kind > 0 && p.canStart(&htmlBlock{kind: kind}, line) {
+			// The line itself is added below.
+			p.addChild(&htmlBlock{kind: kind}, p.column(line)+indent)
+			break
+		} else if level, content := parseATXHeader(line); level > 0 {
+			p.addChild(&atxHeader{level: level, block: block{
+				content:      content,
+				contentLines: []contentLine{{0, p.lineNumber, p.column(content)}},
+			}}, p.column(line)+indent)
+			p.closeLastBlock()
+			return
+		} else if level := parseSetextUnderline(line); containerIsParagraph && level > 0 {
+			// Link reference definitions are not part of the header.
+			p.extractReferenceDefinitions(par)
+			if isBlank(par.content) {
 				p.closeLastBlock()
-				line = nil
-				break
-			} else if isBlank(line) {
-				line = nil
-				break
-			} else if !openBlock.AcceptsLines() {
-				p.addChild(&paragraph{})
-			} else {
-				break
+				p.lastMatched--
+				continue
 			}
-
-			if p.openBlock().AcceptsLines() {
-				break
+			p.replaceOpenBlock(&atxHeader{level: level, block: block{
+				content:      par.content,
+				contentLines: par.contentLines,
+				startLine:    par.startLine,
+				startColumn:  par.startColumn,
+				endLine:      p.lineNumber,
+			}})
+			p.closeLastBlock()
+			return
+		} else if containerIsParagraph && p.options.Tables && p.startTable(par, line) {
+			return
+		} else if padding := parseDefinitionMarker(line); p.options.DefinitionLists && padding > 0 && p.startDefinitionDescription(line, padding) {
+			line = line[padding:]
+		} else if isHorizontalRule(line) {
+			p.addChild(&horizontalRule{}, p.column(line)+indent)
+			p.closeLastBlock()
+			return
+		} else if marker, rest := parseListMarker(line); marker != nil && p.canStart(&listItem{listMarker: *marker}, rest) {
+			p.closeUnmatchedBlocks()
+			if l, ok := p.openBlock().(*listBlock); !ok || !marker.matches(&l.listMarker) {
+				p.addChild(&listBlock{listMarker: *marker}, p.column(line)+indent)
 			}
+			p.addChild(&listItem{listMarker: *marker}, p.column(line)+indent)
+			line = rest
+		} else {
+			break
 		}
+	}
 
-		if line == nil {
-			continue
+	// "3. Text may be added to the last (deepest) open block remaining on the
+	// tree."
+	_, tipIsParagraph := p.openBlock().(*paragraph)
+	if p.lastMatched < len(p.openBlocks)-1 && tipIsParagraph && !isBlank(line) {
+		// "Laziness. If a string of lines Ls constitute a block quote with
+		// contents Bs, then the result of deleting the initial block quote
+		// marker from one or more lines in which the next non-space character
+		// after the block quote marker is paragraph continuation text is a
+		// block quote with Bs as its content."
+		p.appendLine(p.openBlock(), line)
+		return
+	}
+
+	p.closeUnmatchedBlocks()
+	openBlock := p.openBlock()
+	if openBlock.AcceptsLiteralLines() {
+		p.appendLine(openBlock, line)
+		// "If the first line meets both the start condition and the end
+		// condition, the block will contain just that line."
+		if h, ok := openBlock.(*htmlBlock); ok && endsHTMLBlock(h.kind, line) {
+			h.ended = true
+			p.closeLastBlock()
 		}
+	} else if isBlank(line) {
+		return
+	} else if t, ok := openBlock.(*table); ok {
+		addTableRow(t, line, p.lineNumber, p.column(line), false)
+	} else if openBlock.AcceptsLines() {
+		p.appendLine(openBlock, line)
+	} else {
+		p.addChild(&paragraph{}, p.column(line)+indentation(line))
+		p.appendLine(p.openBlock(), line)
+	}
+}
 
-		// "3. Text may be added to the last (deepest) open block remaining on
-		// the tree."
-		openBlock = p.openBlock()
-		assertf(openBlock.AcceptsLines(), "remaining types of block should all accept lines, but %T does not (line: %q)", openBlock, line)
-		openBlock.AppendLine(line)
+// canStart returns whether the given block, which would start on the given
+// line, may be started there. This is always the case, unless the line would
+// otherwise be paragraph continuation text, and the block cannot interrupt a
+// paragraph.
+func (p *blockParser) canStart(b Block, line []byte) bool {
+	interrupted := p.openBlock()
+	if _, ok := b.(*listItem); ok {
+		// Like the reference implementation, a list item only interrupts a
+		// paragraph in its own container, and not one that the line would
+		// continue lazily; this also lets later items of a list end the
+		// paragraph of the previous item.
+		interrupted = p.openBlocks[p.lastMatched]
 	}
-	if err := scanner.Err(); err != nil {
-		return err
+	if _, ok := interrupted.(*paragraph); !ok {
+		return true
 	}
-	return nil
+	return canInterruptParagraph(b, line)
+}
+
+// canInterruptParagraph returns whether the given block may interrupt a
+// paragraph, if it starts on the given line, which is the rest of the line
+// after any block markers. Most blocks can: block quotes, headers, horizontal
+// rules, fenced code blocks, and so on. Blocks that take the place of a
+// paragraph, like setext headers and tables, are not considered to interrupt
+// it.
+func canInterruptParagraph(b Block, line []byte) bool {
+	switch b := b.(type) {
+	case *indentedCodeBlock:
+		// "An indented code block cannot interrupt a paragraph."
+		return false
+	case *htmlBlock:
+		// "HTML blocks of type 7 cannot interrupt a paragraph."
+		return b.kind != 7
+	case *listItem:
+		// "In order for a sequence of lines to constitute a list item, [...]
+		// when the first list item in a list interrupts a paragraph [...] it
+		// must not start with a blank line, [...] an ordered list must start
+		// with 1."
+		return !isBlank(line) && (b.bulletChar != 0 || b.start == 1)
+	}
+	return true
+}
+
+// continueBlock checks whether the line continues the given open block. If so,
+// it returns the rest of the line after any markers belonging to the block
+// have been removed. If the line was consumed entirely and closes the block,
+// the returned line is nil.
+func continueBlock(b Block, line []byte) ([]byte, bool) {
+	indent := indentation(line)
+	blank := line[indent] == '\n'
+
+	switch t := b.(type) {
+	case *indentedCodeBlock:
+		if indent >= 4 {
+			return line[4:], true
+		} else if blank {
+			return line[indent:], true
+		}
+		return line, false
+	case *fencedCodeBlock:
+		// "The closing code fence [...] ends the code block."
+		if t.isClosingFence(line) {
+			t.ended = true
+			return nil, true
+		}
+		return line, true
+	case *htmlBlock:
+		// "End condition: line is followed by a blank line."
+		return line, !(blank && t.kind >= 6)
+	case *paragraph, *table:
+		return line, !blank
+	case *blockQuote:
+		if indent <= 3 && line[indent] == '>' {
+			return stripBlockQuoteMarker(line), true
+		}
+		return line, false
+	case *footnoteDefinition:
+		if blank {
+			return line[indent:], true
+		}
+		if indent >= 4 {
+			return line[4:], true
+		}
+		return line, false
+	case *listBlock, *definitionList:
+		// Whether the list continues depends on its items.
+		return line, true
+	case *listItem:
+		if blank {
+			// "A list item can begin with at most one blank line."
+			if len(t.children) == 0 {
+				return line, false
+			}
+			return line[indent:], true
+		}
+		if indent >= t.markerOffset+t.padding {
+			return line[t.markerOffset+t.padding:], true
+		}
+		return line, false
+	case *definitionDescription:
+		if blank {
+			return line[indent:], true
+		}
+		if indent >= t.padding {
+			return line[t.padding:], true
+		}
+		return line, false
+	}
+	return line, false
 }
 
 // indentation returns the index of the first non-space. If the line consists
@@ -402,6 +1008,54 @@ func parseATXHeader(line []byte) (int, []byte) {
 	return level, line
 }
 
+var codeFenceRe = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})(.*)\n$")
+
+// parseCodeFence recognizes the opening code fence of a fenced code block. It
+// returns the new (empty) block, or nil if the line is not an opening code
+// fence.
+func parseCodeFence(line []byte) *fencedCodeBlock {
+	m := codeFenceRe.FindSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	fence, info := m[2], m[3]
+	// "If the info string comes after a backtick fence, it may not contain any
+	// backtick characters."
+	if fence[0] == '`' && bytes.IndexByte(info, '`') >= 0 {
+		return nil
+	}
+	// "The line with the opening code fence may optionally contain some text
+	// following the code fence; this is trimmed of leading and trailing
+	// spaces and called the info string."
+	return &fencedCodeBlock{
+		fenceChar:   fence[0],
+		fenceLength: len(fence),
+		fenceIndent: len(m[1]),
+		info:        bytes.Trim(info, " "),
+	}
+}
+
+// isClosingFence returns whether the line closes the code block.
+//
+// "The closing code fence may be indented up to three spaces, and may be
+// followed only by spaces, which are ignored."
+func (c *fencedCodeBlock) isClosingFence(line []byte) bool {
+	indent := indentation(line)
+	if indent > 3 {
+		return false
+	}
+	line = line[indent:]
+	var length int
+	for length < len(line) && line[length] == c.fenceChar {
+		length++
+	}
+	// "The closing code fence must be at least as long as the opening fence."
+	if length < c.fenceLength {
+		return false
+	}
+	return isBlank(line[length:])
+}
+
 var setextUnderlineRe = regexp.MustCompile(`^ {0,3}(=+|-+) *\n$`)
 
 // parseSetextUnderline recognizes a setext header underline and returns its
@@ -415,26 +1069,72 @@ func parseSetextUnderline(line []byte) int {
 		case '-':
 			return 2
 		default:
-			assertf(false, "unexpected setext line character '%s'", m[1][0])
+			assertf(false, "unexpected setext line character '%c'", m[1][0])
 		}
 	}
 	return -1
 }
 
-// hasOneLine returns whether the data contains exactly one line. It must be
-// nonempty, and may contain at most one newline character, which must be last.
-func hasOneLine(data []byte) bool {
-	if len(data) == 0 {
-		return false
+// parseListMarker recognizes the marker at the start of a list item. It
+// returns the marker and the rest of the line following it, or nil if the
+// line does not start a list item.
+func parseListMarker(line []byte) (*listMarker, []byte) {
+	indent := indentation(line)
+	if indent >= 4 {
+		return nil, nil
 	}
-	// Skip the last character. If newline, fine, mustn't find another. If not
-	// newline, we mustn't find one elsewhere either.
-	for i := len(data) - 2; i >= 0; i-- {
-		if data[i] == '\n' {
-			return false
+	rest := line[indent:]
+
+	// "A bullet list marker is a -, +, or * character."
+	var marker listMarker
+	var markerLength int
+	switch rest[0] {
+	case '-', '+', '*':
+		marker.bulletChar = rest[0]
+		markerLength = 1
+	default:
+		// "An ordered list marker is a sequence of 1–9 arabic digits (0-9),
+		// followed by either a . character or a ) character. (The reason for
+		// the length limit is that with 10 digits we start seeing integer
+		// overflows in some browsers.)"
+		digits := 0
+		for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 || digits > 9 || (rest[digits] != '.' && rest[digits] != ')') {
+			return nil, nil
+		}
+		start, err := strconv.Atoi(string(rest[:digits]))
+		if err != nil {
+			return nil, nil
 		}
+		marker.delimiter = rest[digits]
+		marker.start = start
+		markerLength = digits + 1
 	}
-	return true
+	rest = rest[markerLength:]
+	marker.markerOffset = indent
+
+	// The marker must be followed by a space or the end of the line.
+	if rest[0] != ' ' && rest[0] != '\n' {
+		return nil, nil
+	}
+	blankItem := isBlank(rest)
+
+	// "If the list item starts with indented code, or is blank, the content
+	// begins one space after the list marker." Otherwise, it begins at the
+	// first non-space character.
+	spaces := indentation(rest)
+	if spaces >= 5 || blankItem {
+		spaces = 1
+		if rest[0] == '\n' {
+			spaces = 0
+		}
+		marker.padding = markerLength + 1
+	} else {
+		marker.padding = markerLength + spaces
+	}
+	return &marker, rest[spaces:]
 }
 
 // stripBlockQuoteMarker removes any leading whitespace, the '>' character, and
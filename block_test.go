@@ -0,0 +1,395 @@
+package commonmark
+
+import (
+	"testing"
+)
+
+func TestATXHeaders(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"# foo\n", "<h1>foo</h1>\n"},
+		{"###### foo\n", "<h6>foo</h6>\n"},
+		{"####### foo\n", "<p>####### foo</p>\n"},
+		{"#foo\n", "<p>#foo</p>\n"},
+		{"## foo ##\n", "<h2>foo</h2>\n"},
+		{"# foo #####   \n", "<h1>foo</h1>\n"},
+		{"# foo#\n", "<h1>foo#</h1>\n"},
+		{"#\n", "<h1></h1>\n"},
+		{"   # foo\n", "<h1>foo</h1>\n"},
+		{"    # foo\n", "<pre><code># foo\n</code></pre>\n"},
+		{"foo\n# bar\nbaz\n", "<p>foo</p>\n<h1>bar</h1>\n<p>baz</p>\n"},
+		{"# `code`\n", "<h1><code>code</code></h1>\n"},
+	})
+}
+
+func TestHorizontalRules(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"***\n", "<hr />\n"},
+		{"---\n", "<hr />\n"},
+		{"- - -\n", "<hr />\n"},
+		{"_ _ _ _\n", "<hr />\n"},
+		{"   ***\n", "<hr />\n"},
+		{"    ***\n", "<pre><code>***\n</code></pre>\n"},
+		{"**\n", "<p>**</p>\n"},
+		{"Foo\n***\nbar\n", "<p>Foo</p>\n<hr />\n<p>bar</p>\n"},
+		{"Foo\n---\n", "<h2>Foo</h2>\n"},
+	})
+}
+
+func TestIndentedCodeBlocks(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"    a simple\n      indented code block\n", "<pre><code>a simple\n  indented code block\n</code></pre>\n"},
+		{"foo\n\n    bar\n", "<p>foo</p>\n<pre><code>bar\n</code></pre>\n"},
+		{"    chunk1\n\n    chunk2\n  \n \n \n    chunk3\n", "<pre><code>chunk1\n\nchunk2\n\n\n\nchunk3\n</code></pre>\n"},
+		{"    chunk1\n      \n      chunk2\n", "<pre><code>chunk1\n  \n  chunk2\n</code></pre>\n"},
+		{"\n    \n    foo\n    \n\n", "<pre><code>foo\n</code></pre>\n"},
+		{"Foo\n    bar\n", "<p>Foo\nbar</p>\n"},
+		{"    foo\nbar\n", "<pre><code>foo\n</code></pre>\n<p>bar</p>\n"},
+		{"\tfoo\n", "<pre><code>foo\n</code></pre>\n"},
+		{"  \tfoo\n", "<pre><code>foo\n</code></pre>\n"},
+		{"    <a/>\n", "<pre><code>&lt;a/&gt;\n</code></pre>\n"},
+		// Tabs after the indentation expand to the tab stops of the line, not
+		// of the code block's content.
+		{"\tfoo\tbar\n", "<pre><code>foo bar\n</code></pre>\n"},
+		{"  \tfoo\tbar\n", "<pre><code>foo bar\n</code></pre>\n"},
+		{"    a\tb\n\tab\tc\n", "<pre><code>a   b\nab  c\n</code></pre>\n"},
+		{"- a\n\n\t\tfoo\tbar\n", "<ul>\n<li><p>a</p>\n<pre><code>  foo bar\n</code></pre></li>\n</ul>\n"},
+		{">\t\tfoo\tbar\n", "<blockquote>\n<pre><code>  foo bar\n</code></pre>\n</blockquote>\n"},
+	})
+}
+
+func TestFencedCodeBlocks(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"```\n<\n >\n```\n", "<pre><code>&lt;\n &gt;\n</code></pre>\n"},
+		{"~~~\n*foo*\n~~~\n", "<pre><code>*foo*\n</code></pre>\n"},
+		{"```go\nfunc main() {}\n```\n", "<pre><code class=\"language-go\">func main() {}\n</code></pre>\n"},
+		{"~~~ ruby startline=3\nputs\n~~~\n", "<pre><code class=\"language-ruby\">puts\n</code></pre>\n"},
+		// The language is escaped, so it cannot break out of the attribute.
+		{"```a\"><script>alert(1)</script>\nx\n```\n", "<pre><code class=\"language-a&quot;&gt;&lt;script&gt;alert(1)&lt;/script&gt;\">x\n</code></pre>\n"},
+		{"```c++&co <iostream>\nx\n```\n", "<pre><code class=\"language-c++&amp;co\">x\n</code></pre>\n"},
+		{"~~~ 'a' onclick=\"b\"\nx\n~~~\n", "<pre><code class=\"language-'a'\">x\n</code></pre>\n"},
+		{"````\naaa\n```\n``````\n", "<pre><code>aaa\n```\n</code></pre>\n"},
+		{"```\naaa\n~~~\n```\n", "<pre><code>aaa\n~~~\n</code></pre>\n"},
+		{"```\nunclosed\n\n", "<pre><code>unclosed\n\n</code></pre>\n"},
+		{"  ```\n  aaa\naaa\n    aaa\n  ```\n", "<pre><code>aaa\naaa\n  aaa\n</code></pre>\n"},
+		{"```\naaa\n    ```\n", "<pre><code>aaa\n    ```\n</code></pre>\n"},
+		{"``` aa ```\nfoo\n", "<p><code>aa</code>\nfoo</p>\n"},
+		{"foo\n```\nbar\n```\nbaz\n", "<p>foo</p>\n<pre><code>bar\n</code></pre>\n<p>baz</p>\n"},
+		// Line endings are normalized inside code blocks too, exactly once.
+		{"```\r\na\r\n\r\nb\rc\r\n```\r\n", "<pre><code>a\n\nb\nc\n</code></pre>\n"},
+		{"```\r\na\r\n```", "<pre><code>a\n</code></pre>\n"},
+		{"```\r\na\r\n", "<pre><code>a\n</code></pre>\n"},
+		{"```\r\na", "<pre><code>a\n</code></pre>\n"},
+		{"> ```\r\n> a\r\n", "<blockquote>\n<pre><code>a\n</code></pre>\n</blockquote>\n"},
+		// In a list item, the indentation of the fence and its content is
+		// counted from the content column of the item.
+		{"- ```\n  code\n  ```\n", "<ul>\n<li><pre><code>code\n</code></pre></li>\n</ul>\n"},
+		{"-  ```\n    code\n     more\n   ```\n", "<ul>\n<li><pre><code> code\n  more\n</code></pre></li>\n</ul>\n"},
+		{"1. ```go\n   x\n    y\n   ```\n", "<ol>\n<li><pre><code class=\"language-go\">x\n y\n</code></pre></li>\n</ol>\n"},
+		{"- ```\n  code\n```\n", "<ul>\n<li><pre><code>code\n</code></pre></li>\n</ul>\n<pre><code></code></pre>\n"},
+		{"- ```\ncode\n```\n", "<ul>\n<li><pre><code></code></pre></li>\n</ul>\n<p>code</p>\n<pre><code></code></pre>\n"},
+	})
+}
+
+func TestParagraphs(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"aaa\n\nbbb\n", "<p>aaa</p>\n<p>bbb</p>\n"},
+		{"aaa\nbbb\n\nccc\nddd\n", "<p>aaa\nbbb</p>\n<p>ccc\nddd</p>\n"},
+		{"aaa\n\n\n\nbbb\n", "<p>aaa</p>\n<p>bbb</p>\n"},
+		{"  aaa\n bbb\n", "<p>aaa\nbbb</p>\n"},
+		{"aaa\n             bbb\n                                       ccc\n", "<p>aaa\nbbb\nccc</p>\n"},
+		{"aaa     \nbbb     \n", "<p>aaa<br />\nbbb</p>\n"},
+		{"aaa\n***\nbbb\n", "<p>aaa</p>\n<hr />\n<p>bbb</p>\n"},
+		{"aaa\n> bbb\n", "<p>aaa</p>\n<blockquote>\n<p>bbb</p>\n</blockquote>\n"},
+	})
+}
+
+func TestParagraphInterruption(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		// Blocks that can interrupt a paragraph.
+		{"aaa\n# bbb\n", "<p>aaa</p>\n<h1>bbb</h1>\n"},
+		{"aaa\n* * *\n", "<p>aaa</p>\n<hr />\n"},
+		{"aaa\n```\nbbb\n```\n", "<p>aaa</p>\n<pre><code>bbb\n</code></pre>\n"},
+		{"aaa\n> bbb\n", "<p>aaa</p>\n<blockquote>\n<p>bbb</p>\n</blockquote>\n"},
+		{"aaa\n<div>\nbbb\n", "<p>aaa</p>\n<div>\nbbb\n"},
+		{"aaa\n<!-- bbb -->\n", "<p>aaa</p>\n<!-- bbb -->\n"},
+		{"aaa\n- bbb\n", "<p>aaa</p>\n<ul>\n<li>bbb</li>\n</ul>\n"},
+		{"aaa\n1. bbb\n", "<p>aaa</p>\n<ol>\n<li>bbb</li>\n</ol>\n"},
+		// Blocks that cannot.
+		{"aaa\n    bbb\n", "<p>aaa\nbbb</p>\n"},
+		{"aaa\n<span>\n", "<p>aaa\n<span></p>\n"},
+		{"aaa\n2. bbb\n", "<p>aaa\n2. bbb</p>\n"},
+		{"aaa\n-\nbbb\n", "<h2>aaa</h2>\n<p>bbb</p>\n"},
+		{"aaa\n*\n", "<p>aaa\n*</p>\n"},
+		{"aaa\n1.\n", "<p>aaa\n1.</p>\n"},
+		// The same applies to lazy continuation lines, except for list items.
+		{"> aaa\n    bbb\n", "<blockquote>\n<p>aaa\nbbb</p>\n</blockquote>\n"},
+		{"> aaa\n<span>\n", "<blockquote>\n<p>aaa\n<span></p>\n</blockquote>\n"},
+		{"> aaa\n# bbb\n", "<blockquote>\n<p>aaa</p>\n</blockquote>\n<h1>bbb</h1>\n"},
+		{"> aaa\n2. bbb\n", "<blockquote>\n<p>aaa</p>\n</blockquote>\n<ol start=\"2\">\n<li>bbb</li>\n</ol>\n"},
+		// Later items in a list are not the first, and always end the
+		// paragraph of the previous one.
+		{"1. aaa\n2. bbb\n3.\n", "<ol>\n<li>aaa</li>\n<li>bbb</li>\n<li></li>\n</ol>\n"},
+		// Nor is anything interrupted outside paragraphs.
+		{"# aaa\n    bbb\n", "<h1>aaa</h1>\n<pre><code>bbb\n</code></pre>\n"},
+		{"***\n2. aaa\n", "<hr />\n<ol start=\"2\">\n<li>aaa</li>\n</ol>\n"},
+	})
+}
+
+func TestBlankLines(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		// Runs of blank lines between blocks produce no output, wherever they
+		// are.
+		{"\n\n\n# aaa\n\n\n\n> bbb\n>\n>\n\n\n***\n\n\n\nccc\n", "<h1>aaa</h1>\n<blockquote>\n<p>bbb</p>\n</blockquote>\n<hr />\n<p>ccc</p>\n"},
+		{"  \n\t\n \naaa\n \n\t \n\nbbb\n", "<p>aaa</p>\n<p>bbb</p>\n"},
+		{"> aaa\n>\n>   \n>\n> bbb\n", "<blockquote>\n<p>aaa</p>\n<p>bbb</p>\n</blockquote>\n"},
+		// Trailing blank lines and whitespace at the end of the document are
+		// dropped, and the output ends in a single newline.
+		{"aaa\n\n\n\n\n", "<p>aaa</p>\n"},
+		{"aaa  \n   \n\t\n  ", "<p>aaa</p>\n"},
+		{"aaa\r\n\r\n\r\n", "<p>aaa</p>\n"},
+		{"    code\n\n\n\n", "<pre><code>code\n</code></pre>\n"},
+		{"- aaa\n\n\n\n", "<ul>\n<li>aaa</li>\n</ul>\n"},
+		{"\n\n\n", ""},
+		// Blank lines inside code are preserved.
+		{"    aaa\n\n\n    bbb\n\n\n", "<pre><code>aaa\n\n\nbbb\n</code></pre>\n"},
+		{"```\naaa\n\n\n```\n\n\n", "<pre><code>aaa\n\n\n</code></pre>\n"},
+	})
+}
+
+func TestSetextHeaders(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"Foo\n===\n", "<h1>Foo</h1>\n"},
+		{"Foo\n-\n", "<h2>Foo</h2>\n"},
+		{"Foo\n---------------\n", "<h2>Foo</h2>\n"},
+		{"Foo\nbar\n===\n", "<h1>Foo\nbar</h1>\n"},
+		{"Foo\n\n---\n", "<p>Foo</p>\n<hr />\n"},
+		{"Foo\n\n===\n", "<p>Foo</p>\n<p>===</p>\n"},
+		{"   Foo\n   ===\n", "<h1>Foo</h1>\n"},
+		{"    Foo\n    ===\n", "<pre><code>Foo\n===\n</code></pre>\n"},
+		{"Foo\n    ---\n", "<p>Foo\n---</p>\n"},
+		{"Foo  \n---\n", "<h2>Foo</h2>\n"},
+		{"Foo\n= =\n", "<p>Foo\n= =</p>\n"},
+		// An underline needs a paragraph with content right before it.
+		{"foo\n-\n", "<h2>foo</h2>\n"},
+		{"---\n", "<hr />\n"},
+		{"\n---\n", "<hr />\n"},
+		{"-\n---\n", "<ul>\n<li></li>\n</ul>\n<hr />\n"},
+		{"-\n-\n", "<ul>\n<li></li>\n<li></li>\n</ul>\n"},
+		{"- foo\n-\n", "<ul>\n<li>foo</li>\n<li></li>\n</ul>\n"},
+		{"- foo\n  -\n", "<ul>\n<li><h2>foo</h2></li>\n</ul>\n"},
+		{"[a]: /b\n---\n", "<hr />\n"},
+		{"    foo\n---\n", "<pre><code>foo\n</code></pre>\n<hr />\n"},
+		{"=\n", "<p>=</p>\n"},
+	})
+}
+
+func TestLinkReferenceDefinitions(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"[foo]: /url \"title\"\n\n[foo]\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+		{"   [foo]: \n      /url  \n           'the title'  \n\n[foo]\n", "<p><a href=\"/url\" title=\"the title\">foo</a></p>\n"},
+		{"[foo]: /url '\ntitle\nline1\nline2\n'\n\n[foo]\n", "<p><a href=\"/url\" title=\"\ntitle\nline1\nline2\n\">foo</a></p>\n"},
+		{"[foo]:\n/url\n\n[foo]\n", "<p><a href=\"/url\">foo</a></p>\n"},
+		{"[foo]:\n\n[foo]\n", "<p>[foo]:</p>\n<p>[foo]</p>\n"},
+		{"[foo]\n\n[foo]: url\n", "<p><a href=\"url\">foo</a></p>\n"},
+		{"[FOO]: /url\n\n[Foo]\n", "<p><a href=\"/url\">Foo</a></p>\n"},
+		{"[foo]: /url\n", ""},
+		{"[foo]: /url \"title\" ok\n", "<p>[foo]: /url &quot;title&quot; ok</p>\n"},
+		{"    [foo]: /url \"title\"\n\n[foo]\n", "<pre><code>[foo]: /url &quot;title&quot;\n</code></pre>\n<p>[foo]</p>\n"},
+		{"Foo\n[bar]: /baz\n\n[bar]\n", "<p>Foo\n[bar]: /baz</p>\n<p>[bar]</p>\n"},
+		{"# [Foo]\n[foo]: /url\n> bar\n", "<h1><a href=\"/url\">Foo</a></h1>\n<blockquote>\n<p>bar</p>\n</blockquote>\n"},
+		{"[foo]: /foo-url \"foo\"\n[bar]: /bar-url\n  \"bar\"\n[baz]: /baz-url\n\n[foo],\n[bar],\n[baz]\n",
+			"<p><a href=\"/foo-url\" title=\"foo\">foo</a>,\n<a href=\"/bar-url\" title=\"bar\">bar</a>,\n<a href=\"/baz-url\">baz</a></p>\n"},
+		{"[foo]: /url\n===\n[foo]\n", "<p>===\n<a href=\"/url\">foo</a></p>\n"},
+		// Duplicate labels: the first definition wins, and none are output.
+		{"[foo]: /a\n[foo]: /b\n\n[foo]\n", "<p><a href=\"/a\">foo</a></p>\n"},
+		{"[foo]\n\n[Foo]: /a \"A\"\n\n> [FOO]: /b \"B\"\n", "<p><a href=\"/a\" title=\"A\">foo</a></p>\n<blockquote>\n</blockquote>\n"},
+		{"[ÄÖ]: /a\n[äö]: /b\n\n[Äö]\n", "<p><a href=\"/a\">Äö</a></p>\n"},
+		// Titles on the next line, and lines after the destination that turn
+		// out not to be titles.
+		{"[foo]: /url \"title\"\n[foo]\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+		{"[foo]: /url\n\"title\"\n[foo]\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+		{"[foo]: /url\n   (title)  \n[foo]\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+		{"[foo]: /url\n\"title\" ok\n[foo]\n", "<p>&quot;title&quot; ok\n<a href=\"/url\">foo</a></p>\n"},
+		{"[foo]: /url\n\"title\n\n[foo]\n", "<p>&quot;title</p>\n<p><a href=\"/url\">foo</a></p>\n"},
+		{"[foo]: /url\n[bar]: /bar\n\n[foo] [bar]\n", "<p><a href=\"/url\">foo</a> <a href=\"/bar\">bar</a></p>\n"},
+	})
+}
+
+func TestBlockQuotes(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"> # Foo\n> bar\n> baz\n", "<blockquote>\n<h1>Foo</h1>\n<p>bar\nbaz</p>\n</blockquote>\n"},
+		{"># Foo\n>bar\n> baz\n", "<blockquote>\n<h1>Foo</h1>\n<p>bar\nbaz</p>\n</blockquote>\n"},
+		{"   > foo\n", "<blockquote>\n<p>foo</p>\n</blockquote>\n"},
+		{"    > foo\n", "<pre><code>&gt; foo\n</code></pre>\n"},
+		{"> bar\nbaz\n> foo\n", "<blockquote>\n<p>bar\nbaz\nfoo</p>\n</blockquote>\n"},
+		{"> foo\n---\n", "<blockquote>\n<p>foo</p>\n</blockquote>\n<hr />\n"},
+		{">     foo\n    bar\n", "<blockquote>\n<pre><code>foo\n</code></pre>\n</blockquote>\n<pre><code>bar\n</code></pre>\n"},
+		{"> ```\nfoo\n```\n", "<blockquote>\n<pre><code></code></pre>\n</blockquote>\n<p>foo</p>\n<pre><code></code></pre>\n"},
+		{"> foo\n    - bar\n", "<blockquote>\n<p>foo\n- bar</p>\n</blockquote>\n"},
+		{">\n", "<blockquote>\n</blockquote>\n"},
+		{"> foo\n\n> bar\n", "<blockquote>\n<p>foo</p>\n</blockquote>\n<blockquote>\n<p>bar</p>\n</blockquote>\n"},
+		{"> foo\n>\n> bar\n", "<blockquote>\n<p>foo</p>\n<p>bar</p>\n</blockquote>\n"},
+		{"foo\n> bar\n", "<p>foo</p>\n<blockquote>\n<p>bar</p>\n</blockquote>\n"},
+		{"> aaa\n***\n> bbb\n", "<blockquote>\n<p>aaa</p>\n</blockquote>\n<hr />\n<blockquote>\n<p>bbb</p>\n</blockquote>\n"},
+		{"> bar\n>\nbaz\n", "<blockquote>\n<p>bar</p>\n</blockquote>\n<p>baz</p>\n"},
+		{"> > > foo\nbar\n", "<blockquote>\n<blockquote>\n<blockquote>\n<p>foo\nbar</p>\n</blockquote>\n</blockquote>\n</blockquote>\n"},
+		{">>> foo\n> bar\n>>baz\n", "<blockquote>\n<blockquote>\n<blockquote>\n<p>foo\nbar\nbaz</p>\n</blockquote>\n</blockquote>\n</blockquote>\n"},
+		{"> ```\n> code\n> ```\n", "<blockquote>\n<pre><code>code\n</code></pre>\n</blockquote>\n"},
+		// A tab after the marker counts as the spaces up to the next tab
+		// stop, of which the marker takes only the first.
+		{">\tfoo\n", "<blockquote>\n<p>foo</p>\n</blockquote>\n"},
+		{"  >\tfoo\n", "<blockquote>\n<p>foo</p>\n</blockquote>\n"},
+		{">\t\tfoo\n", "<blockquote>\n<pre><code>  foo\n</code></pre>\n</blockquote>\n"},
+		{">\t  foo\n", "<blockquote>\n<pre><code>foo\n</code></pre>\n</blockquote>\n"},
+	})
+}
+
+func TestBulletLists(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"- foo\n- bar\n", "<ul>\n<li>foo</li>\n<li>bar</li>\n</ul>\n"},
+		{"* foo\n+ bar\n", "<ul>\n<li>foo</li>\n</ul>\n<ul>\n<li>bar</li>\n</ul>\n"},
+		{"- foo\n\n- bar\n", "<ul>\n<li><p>foo</p></li>\n<li><p>bar</p></li>\n</ul>\n"},
+		{"- foo\n\n  bar\n", "<ul>\n<li><p>foo</p>\n<p>bar</p></li>\n</ul>\n"},
+		{"- foo\n  - bar\n    - baz\n", "<ul>\n<li>foo\n<ul>\n<li>bar\n<ul>\n<li>baz</li>\n</ul></li>\n</ul></li>\n</ul>\n"},
+		{"- foo\nbar\n", "<ul>\n<li>foo\nbar</li>\n</ul>\n"},
+		{"- foo\n---\n", "<ul>\n<li>foo</li>\n</ul>\n<hr />\n"},
+		{"-\n- bar\n", "<ul>\n<li></li>\n<li>bar</li>\n</ul>\n"},
+		{"-foo\n", "<p>-foo</p>\n"},
+		{"foo\n-\n", "<h2>foo</h2>\n"},
+		{"foo\n- bar\n", "<p>foo</p>\n<ul>\n<li>bar</li>\n</ul>\n"},
+		{"- > foo\n", "<ul>\n<li><blockquote>\n<p>foo</p>\n</blockquote></li>\n</ul>\n"},
+		{"-     code\n", "<ul>\n<li><pre><code>code\n</code></pre></li>\n</ul>\n"},
+		{"- foo\n\n\n  bar\n", "<ul>\n<li><p>foo</p>\n<p>bar</p></li>\n</ul>\n"},
+		// The same goes for a tab after a list marker.
+		{"-\tfoo\n", "<ul>\n<li>foo</li>\n</ul>\n"},
+		{"1.\tfoo\n", "<ol>\n<li>foo</li>\n</ol>\n"},
+		{"-\t\tfoo\n", "<ul>\n<li><pre><code>  foo\n</code></pre></li>\n</ul>\n"},
+		{" -\tfoo\n\n\tbar\n", "<ul>\n<li><p>foo</p>\n<p>bar</p></li>\n</ul>\n"},
+	})
+}
+
+func TestListItemsStartingWithBlankLine(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		// "When the list item starts with a blank line, the number of spaces
+		// following the list marker doesn't change the required indentation."
+		{"-\n  foo\n", "<ul>\n<li>foo</li>\n</ul>\n"},
+		{"-   \n  foo\n", "<ul>\n<li>foo</li>\n</ul>\n"},
+		{"1.\n   foo\n", "<ol>\n<li>foo</li>\n</ol>\n"},
+		{"-\n  ```\n  bar\n  ```\n-\n      baz\n", "<ul>\n<li><pre><code>bar\n</code></pre></li>\n<li><pre><code>baz\n</code></pre></li>\n</ul>\n"},
+		// Empty items.
+		{"-\n", "<ul>\n<li></li>\n</ul>\n"},
+		{"- a\n-\n- c\n", "<ul>\n<li>a</li>\n<li></li>\n<li>c</li>\n</ul>\n"},
+		{"1. a\n2.\n3. c\n", "<ol>\n<li>a</li>\n<li></li>\n<li>c</li>\n</ol>\n"},
+		// "A list item can begin with at most one blank line": the blank line
+		// after an empty item ends it.
+		{"-\n\n  foo\n", "<ul>\n<li></li>\n</ul>\n<p>foo</p>\n"},
+		{"-\n\n\n  foo\n", "<ul>\n<li></li>\n</ul>\n<p>foo</p>\n"},
+		// An empty item cannot interrupt a paragraph.
+		{"foo\n*\n", "<p>foo\n*</p>\n"},
+	})
+}
+
+func TestListTightness(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		// Tight lists.
+		{"- a\n- b\n- c\n", "<ul>\n<li>a</li>\n<li>b</li>\n<li>c</li>\n</ul>\n"},
+		{"- a\n- b\n\n\n", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{"- a\n  > b\n  >\n- c\n", "<ul>\n<li>a\n<blockquote>\n<p>b</p>\n</blockquote></li>\n<li>c</li>\n</ul>\n"},
+		{"- a\n  ```\n  b\n\n\n  ```\n- c\n", "<ul>\n<li>a\n<pre><code>b\n\n\n</code></pre></li>\n<li>c</li>\n</ul>\n"},
+		// Loose because items are separated by blank lines.
+		{"- a\n- b\n\n- c\n", "<ul>\n<li><p>a</p></li>\n<li><p>b</p></li>\n<li><p>c</p></li>\n</ul>\n"},
+		{"- a\n  - b\n\n- c\n", "<ul>\n<li><p>a</p>\n<ul>\n<li>b</li>\n</ul></li>\n<li><p>c</p></li>\n</ul>\n"},
+		// Loose because an item contains blocks separated by blank lines.
+		{"- a\n\n  b\n- c\n", "<ul>\n<li><p>a</p>\n<p>b</p></li>\n<li><p>c</p></li>\n</ul>\n"},
+		{"1. a\n\n   > b\n", "<ol>\n<li><p>a</p>\n<blockquote>\n<p>b</p>\n</blockquote></li>\n</ol>\n"},
+		{"- a\n  - b\n\n\n  c\n", "<ul>\n<li><p>a</p>\n<ul>\n<li>b</li>\n</ul>\n<p>c</p></li>\n</ul>\n"},
+		// Tightness is decided for each list separately.
+		{"- a\n  - b\n\n    c\n- d\n", "<ul>\n<li>a\n<ul>\n<li><p>b</p>\n<p>c</p></li>\n</ul></li>\n<li>d</li>\n</ul>\n"},
+		{"- a\n\n- b\n  - c\n  - d\n", "<ul>\n<li><p>a</p></li>\n<li><p>b</p>\n<ul>\n<li>c</li>\n<li>d</li>\n</ul></li>\n</ul>\n"},
+	})
+}
+
+func TestListItemIndentedCode(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		// The four spaces of an indented code block are counted from the
+		// content column of the item, not from the start of the line.
+		{"- foo\n\n      bar\n        baz\n", "<ul>\n<li><p>foo</p>\n<pre><code>bar\n  baz\n</code></pre></li>\n</ul>\n"},
+		{"1.  A paragraph\n\n        indented code\n\n    > quote\n",
+			"<ol>\n<li><p>A paragraph</p>\n<pre><code>indented code\n</code></pre>\n<blockquote>\n<p>quote</p>\n</blockquote></li>\n</ol>\n"},
+		{"10. foo\n\n        code\n", "<ol start=\"10\">\n<li><p>foo</p>\n<pre><code>code\n</code></pre></li>\n</ol>\n"},
+		{"- foo\n\n     bar\n", "<ul>\n<li><p>foo</p>\n<p>bar</p></li>\n</ul>\n"},
+		// An item can start with indented code, in which case its content
+		// starts one space after the marker.
+		{"-     code\n      more\n", "<ul>\n<li><pre><code>code\nmore\n</code></pre></li>\n</ul>\n"},
+		{"1.      code\n\n   para\n", "<ol>\n<li><pre><code> code\n</code></pre>\n<p>para</p></li>\n</ol>\n"},
+		// Tabs are expanded before the content column is stripped.
+		{"- foo\n\n\t\tbar\n", "<ul>\n<li><p>foo</p>\n<pre><code>  bar\n</code></pre></li>\n</ul>\n"},
+		{"-\tfoo\n\n\tbar\n\n\t    code\n", "<ul>\n<li><p>foo</p>\n<p>bar</p>\n<pre><code>code\n</code></pre></li>\n</ul>\n"},
+	})
+}
+
+func TestListItemContentColumn(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		// The content column is the width of the marker plus the one to four
+		// spaces after it.
+		{"1.  text\n\n    more\n", "<ol>\n<li><p>text</p>\n<p>more</p></li>\n</ol>\n"},
+		{"1.  text\n\n   not more\n", "<ol>\n<li>text</li>\n</ol>\n<p>not more</p>\n"},
+		{"1.    four\n", "<ol>\n<li>four</li>\n</ol>\n"},
+		{"10.  foo\n\n     bar\n", "<ol start=\"10\">\n<li><p>foo</p>\n<p>bar</p></li>\n</ol>\n"},
+		{"10.  foo\n\n    bar\n", "<ol start=\"10\">\n<li>foo</li>\n</ol>\n<pre><code>bar\n</code></pre>\n"},
+		// With five or more spaces, the content starts one space after the
+		// marker, and the rest of the spaces start an indented code block.
+		{"1.     code\n", "<ol>\n<li><pre><code>code\n</code></pre></li>\n</ol>\n"},
+		{"1.     code\n\n   para\n", "<ol>\n<li><pre><code>code\n</code></pre>\n<p>para</p></li>\n</ol>\n"},
+		{"10.      code\n\n    para\n", "<ol start=\"10\">\n<li><pre><code> code\n</code></pre>\n<p>para</p></li>\n</ol>\n"},
+	})
+}
+
+func TestOrderedLists(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"1. foo\n2. bar\n", "<ol>\n<li>foo</li>\n<li>bar</li>\n</ol>\n"},
+		{"3. foo\n7. bar\n", "<ol start=\"3\">\n<li>foo</li>\n<li>bar</li>\n</ol>\n"},
+		{"0. foo\n", "<ol start=\"0\">\n<li>foo</li>\n</ol>\n"},
+		{"003) foo\n", "<ol start=\"3\">\n<li>foo</li>\n</ol>\n"},
+		{"1. foo\n2) bar\n", "<ol>\n<li>foo</li>\n</ol>\n<ol start=\"2\">\n<li>bar</li>\n</ol>\n"},
+		{"1. foo\n- bar\n", "<ol>\n<li>foo</li>\n</ol>\n<ul>\n<li>bar</li>\n</ul>\n"},
+		{"foo\n1. bar\n", "<p>foo</p>\n<ol>\n<li>bar</li>\n</ol>\n"},
+		{"foo\n14. bar\n", "<p>foo\n14. bar</p>\n"},
+		{"1.foo\n", "<p>1.foo</p>\n"},
+		{"-1. foo\n", "<p>-1. foo</p>\n"},
+		{"10. foo\n    bar\n", "<ol start=\"10\">\n<li>foo\nbar</li>\n</ol>\n"},
+		{"1. foo\n\n   - bar\n", "<ol>\n<li><p>foo</p>\n<ul>\n<li>bar</li>\n</ul></li>\n</ol>\n"},
+		// Start numbers have at most 9 digits.
+		{"123456789. ok\n", "<ol start=\"123456789\">\n<li>ok</li>\n</ol>\n"},
+		{"000000001) ok\n", "<ol>\n<li>ok</li>\n</ol>\n"},
+		{"1234567890. not ok\n", "<p>1234567890. not ok</p>\n"},
+		{"0000000001) not ok\n", "<p>0000000001) not ok</p>\n"},
+		{"99999999999999999999. not ok\n", "<p>99999999999999999999. not ok</p>\n"},
+		{"1. ok\n1234567890. not ok\n", "<ol>\n<li>ok\n1234567890. not ok</li>\n</ol>\n"},
+	})
+}
+
+func TestHTMLBlocks(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"<pre language=\"haskell\"><code>\nimport Text.HTML.TagSoup\n\nmain :: IO ()\n</code></pre>\nokay\n",
+			"<pre language=\"haskell\"><code>\nimport Text.HTML.TagSoup\n\nmain :: IO ()\n</code></pre>\n<p>okay</p>\n"},
+		{"<script>\n*foo*\n</SCRIPT> *bar*\n*baz*\n", "<script>\n*foo*\n</SCRIPT> *bar*\n<p><em>baz</em></p>\n"},
+		{"<!-- Foo\n\nbar\n   baz -->\nokay\n", "<!-- Foo\n\nbar\n   baz -->\n<p>okay</p>\n"},
+		{"<!-- foo -->*bar*\n*baz*\n", "<!-- foo -->*bar*\n<p><em>baz</em></p>\n"},
+		{"<?php\n\n  echo '>';\n\n?>\n", "<?php\n\n  echo '>';\n\n?>\n"},
+		{"<!DOCTYPE html>\n", "<!DOCTYPE html>\n"},
+		{"<![CDATA[\nfoo\n\nbar\n]]>\n", "<![CDATA[\nfoo\n\nbar\n]]>\n"},
+		{"<div>\n*hello*\n         <foo><a>\n\n*world*\n", "<div>\n*hello*\n         <foo><a>\n<p><em>world</em></p>\n"},
+		{"<DIV CLASS=\"foo\">\n\n*Markdown*\n\n</DIV>\n", "<DIV CLASS=\"foo\">\n<p><em>Markdown</em></p>\n</DIV>\n"},
+		{"  <div>\n    foo\n</div>\n", "  <div>\n    foo\n</div>\n"},
+		{"    <div>\n", "<pre><code>&lt;div&gt;\n</code></pre>\n"},
+		{"Foo\n<div>\nbar\n</div>\n", "<p>Foo</p>\n<div>\nbar\n</div>\n"},
+		{"<a href=\"foo\">\n*bar*\n</a>\n", "<a href=\"foo\">\n*bar*\n</a>\n"},
+		{"<del>*foo*</del>\n", "<p><del><em>foo</em></del></p>\n"},
+		{"Foo\n<a href=\"bar\">\nbaz\n", "<p>Foo\n<a href=\"bar\">\nbaz</p>\n"},
+		{"> <div>\n> foo\n\nbar\n", "<blockquote>\n<div>\nfoo\n</blockquote>\n<p>bar</p>\n"},
+		{"- <div>\n- foo\n", "<ul>\n<li><div></li>\n<li>foo</li>\n</ul>\n"},
+		{"<pre/>\n*foo*\n", "<p><pre/>\n<em>foo</em></p>\n"},
+		{"<foo/>\n*foo*\n", "<foo/>\n*foo*\n"},
+	})
+}
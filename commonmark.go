@@ -4,6 +4,8 @@ package commonmark
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 )
 
 // ToHTMLBytes converts text formatted in CommonMark into the corresponding
@@ -12,23 +14,200 @@ import (
 // The input must be encoded as UTF-8.
 //
 // Line breaks in the output will be single '\n' bytes, regardless of line
-// endings in the input (which can be CR, LF or CRLF).
+// endings in the input (which can be CR, LF or CRLF). Use Options.LineEnding
+// to change this.
 //
 // Note that the output might contain unsafe tags (e.g. <script>); if you are
-// accepting untrusted user input, you must run the output through a sanitizer
-// before sending it to a browser.
+// accepting untrusted user input, you must either enable Options.Safe or run
+// the output through a sanitizer before sending it to a browser.
 func ToHTMLBytes(data []byte) ([]byte, error) {
-	doc, err := parse(data)
+	return AppendHTML(nil, data)
+}
+
+// ToHTMLBytesWithOptions is like ToHTMLBytes, but allows the conversion to be
+// configured. ToHTMLBytes is equivalent to passing the zero value of Options.
+//
+// To convert many documents with the same options, a Parser avoids doing the
+// same setup for each of them.
+func ToHTMLBytesWithOptions(data []byte, options Options) ([]byte, error) {
+	return NewParser(options).ToHTMLBytes(data)
+}
+
+// AppendHTML is like ToHTMLBytes, but appends the HTML to dst and returns the
+// extended slice, in the manner of the append built-in. This way, the HTML of
+// several documents can be concatenated in one slice, which is only
+// reallocated when it runs out of room. The HTML of each document is rendered
+// into a reused internal buffer first, and then copied into dst once. If an
+// error occurs, dst is returned unchanged.
+func AppendHTML(dst, markdown []byte) ([]byte, error) {
+	return NewParser(Options{}).AppendHTML(dst, markdown)
+}
+
+// ToHTMLBytesMulti converts several documents that are shown on the same
+// page, such as the comments on a post, into HTML, and returns their HTML
+// concatenated. Each document is converted as ToHTMLBytesWithOptions would,
+// with its footnotes listed at its end, except that footnotes are numbered
+// on from those of the documents before it, and the ids of headings and
+// footnotes are unique across all documents.
+//
+// Link reference definitions only apply to the document that contains them,
+// unless Options.ShareReferenceDefinitions is enabled.
+func ToHTMLBytesMulti(docs [][]byte, options Options) ([]byte, error) {
+	renderer := NewHTMLRenderer(options)
+	set := &documentSet{}
+	var buffer bytes.Buffer
+	for _, data := range docs {
+		doc, err := parseInSet(bytes.NewReader(data), data, &options, set)
+		if err != nil {
+			return nil, err
+		}
+		if err := Render(&buffer, documentToNode(doc), renderer); err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+// documentSet holds what is shared between the documents converted together
+// by ToHTMLBytesMulti. The zero value is an empty set; its maps are created
+// when they are needed.
+type documentSet struct {
+	// headingIDs and footnoteIDs hold the ids used so far.
+	headingIDs  slugSet
+	footnoteIDs slugSet
+	// numFootnotes is the number of footnotes numbered so far.
+	numFootnotes int
+	// references holds the link reference definitions of the documents so
+	// far, with Options.ShareReferenceDefinitions.
+	references referenceMap
+}
+
+// shareReferences adds the definitions of earlier documents to references,
+// except where a document has its own, and then adds its new definitions to
+// the set. As within a document, the first definition of a label is used.
+func (s *documentSet) shareReferences(references referenceMap) {
+	if s.references == nil {
+		s.references = make(referenceMap)
+	}
+	for key, ref := range s.references {
+		if _, ok := references[key]; !ok {
+			references[key] = ref
+		}
+	}
+	for key, ref := range references {
+		if _, ok := s.references[key]; !ok {
+			s.references[key] = ref
+		}
+	}
+}
+
+// ToHTML is like ToHTMLBytes, but takes and returns strings.
+func ToHTML(markdown string) (string, error) {
+	html, err := ToHTMLBytes([]byte(markdown))
 	if err != nil {
+		return "", err
+	}
+	return string(html), nil
+}
+
+// ToHTMLInline converts a fragment of text formatted in CommonMark, such as a
+// label or a title, into HTML. Only inline content is recognized, as if the
+// fragment were the text of a paragraph, but the output is not wrapped in <p>
+// tags. Line breaks in the input become soft or hard line breaks, and
+// whitespace at the start and end of each line is ignored, as in a paragraph.
+// Because a fragment cannot contain link reference definitions, reference
+// links are left as they are.
+func ToHTMLInline(markdown []byte) ([]byte, error) {
+	var content []byte
+	s := newScanner(bytes.NewReader(markdown))
+	for s.Scan() {
+		content = append(content, bytes.TrimLeft(replaceNULs(s.Bytes()), " \t")...)
+		content = append(content, '\n')
+	}
+	if err := s.Err(); err != nil {
 		return nil, err
 	}
 
+	options := &Options{}
+	par := &paragraph{}
+	par.inlineContent = parseInlines(content, nil, &footnoteMap{}, options)
+	n := blockToNode(par, &sourceMap{})
 	var buffer bytes.Buffer
-	blockToHTML(doc, &buffer)
+	for child := n.firstChild; child != nil; child = child.next {
+		if err := Render(&buffer, child, NewHTMLRenderer(*options)); err != nil {
+			return nil, err
+		}
+	}
 	return buffer.Bytes(), nil
 }
 
-func parse(data []byte) (*document, error) {
+// Convert reads text formatted in CommonMark from r and writes the
+// corresponding HTML to w. See ToHTMLBytes for details on input and output.
+//
+// The input is read line by line and the output is written directly to w, so
+// neither is buffered in its entirety. However, the parse tree of the whole
+// document must be constructed before any output can be written (for example,
+// link references may be defined after they are used), so memory use is still
+// proportional to the size of the document.
+//
+// The first error returned by r or w is returned.
+func Convert(w io.Writer, r io.Reader) error {
+	return convert(w, r, nil, &Options{})
+}
+
+// convert reads CommonMark from r and writes HTML to w. If the input is
+// available in memory, it is passed as input too, and r must read from it.
+func convert(w io.Writer, r io.Reader, input []byte, options *Options) error {
+	doc, err := parse(r, input, options)
+	if err != nil {
+		return err
+	}
+
+	return Render(w, documentToNode(doc), NewHTMLRenderer(*options))
+}
+
+// ParseError is returned for input that is not valid in strict mode; see
+// Options.Strict.
+type ParseError struct {
+	// Line is the 1-based line number where the problem starts.
+	Line int
+	// Col is the 1-based column where the problem starts, in the same way as
+	// Position.Column.
+	Col int
+	// Msg describes the problem.
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("commonmark: line %d, column %d: %s", e.Line, e.Col, e.Msg)
+}
+
+// errWriter wraps an io.Writer and remembers the first error that occurred.
+// After an error, writes are discarded.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	var n int
+	n, e.err = e.w.Write(p)
+	return n, e.err
+}
+
+// parse parses the CommonMark read from r. If the input is available in
+// memory, it is passed as input too, and r must read from it; lines are then
+// used in place where possible, instead of being copied.
+func parse(r io.Reader, input []byte, options *Options) (*document, error) {
+	return parseInSet(r, input, options, &documentSet{})
+}
+
+// parseInSet is like parse, but numbers footnotes and assigns ids as part of
+// the given set of documents.
+func parseInSet(r io.Reader, input []byte, options *Options, set *documentSet) (*document, error) {
 	// See http://spec.commonmark.org/0.7/#appendix-a-a-parsing-strategy
 	// "Parsing has two phases:"
 
@@ -37,7 +216,7 @@ func parse(data []byte) (*document, error) {
 	// and so on—is constructed. Text is assigned to these blocks but not
 	// parsed. Link reference definitions are parsed and a map of links is
 	// constructed."
-	doc, err := parseBlocks(data)
+	doc, err := parseBlocks(r, input, options)
 	if err != nil {
 		return nil, err
 	}
@@ -46,22 +225,42 @@ func parse(data []byte) (*document, error) {
 	// are parsed into sequences of Markdown inline elements (strings, code
 	// spans, links, emphasis, and so on), using the map of link references
 	// constructed in phase 1."
-	processInlines(doc)
+	if options.ShareReferenceDefinitions {
+		set.shareReferences(doc.references)
+	}
+	addReferenceDefinitions(doc.references, options.ReferenceDefinitions)
+	doc.footnotes.offset = set.numFootnotes
+	doc.footnotes.ids = set.footnoteIDs
+	processInlines(doc, doc.references, &doc.footnotes, options)
+	set.numFootnotes += len(doc.footnotes.referenced)
+	set.footnoteIDs = doc.footnotes.ids
+	if options.HeadingIDs {
+		if set.headingIDs == nil {
+			set.headingIDs = slugSet{}
+		}
+		assignHeadingIDs(doc, set.headingIDs, options.Slugify)
+	}
 
 	return doc, nil
 }
 
-func processInlines(b Block) {
+func processInlines(b Block, references referenceMap, footnotes *footnoteMap, options *Options) {
 	switch t := b.(type) {
 	case *atxHeader:
-		t.inlineContent = parseInlines(t.content)
+		t.inlineContent = parseInlines(t.content, references, footnotes, options)
 	case *paragraph:
 		// "Final spaces are stripped before inline parsing, so a paragraph that
 		// ends with two or more spaces will not end with a hard line break."
-		t.inlineContent = parseInlines(bytes.TrimRight(t.content, " "))
+		t.inlineContent = parseInlines(bytes.TrimRight(t.content, " "), references, footnotes, options)
+	case *tableCell, *definitionTerm:
+		t.base().inlineContent = parseInlines(t.base().content, references, footnotes, options)
+	case *listItem:
+		if options.TaskLists {
+			t.parseTaskListMarker()
+		}
 	}
 
 	for _, child := range b.Children() {
-		processInlines(child)
+		processInlines(child, references, footnotes, options)
 	}
 }
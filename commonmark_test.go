@@ -0,0 +1,322 @@
+package commonmark
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestToHTMLMatchesToHTMLBytes(t *testing.T) {
+	inputs := []string{
+		"",
+		"foo",
+		"# foo\r\nbar\rbaz\n",
+		"> quoted\n\n    code\n",
+	}
+	for _, input := range inputs {
+		expected, err := ToHTMLBytes([]byte(input))
+		if err != nil {
+			t.Fatalf("ToHTMLBytes(%q) returned error: %s", input, err)
+		}
+		actual, err := ToHTML(input)
+		if err != nil {
+			t.Fatalf("ToHTML(%q) returned error: %s", input, err)
+		}
+		if actual != string(expected) {
+			t.Errorf("ToHTML(%q) = %q, but ToHTMLBytes returned %q", input, actual, expected)
+		}
+	}
+}
+
+func TestToHTMLInline(t *testing.T) {
+	tests := []conversionTest{
+		{"*hi* **there**", "<em>hi</em> <strong>there</strong>"},
+		{"*hi* **there**\n", "<em>hi</em> <strong>there</strong>"},
+		{"", ""},
+		{"# not a header", "# not a header"},
+		{"- not a list\n> not a quote", "- not a list\n&gt; not a quote"},
+		{"  a  \r\n  b\\", "a<br />\nb\\"},
+		{"[x](/y \"t\") [z]\n\n[z]: /url", "<a href=\"/y\" title=\"t\">x</a> [z]\n\n[z]: /url"},
+		{"<b>x</b> & `c`\x00", "<b>x</b> &amp; <code>c</code>\ufffd"},
+	}
+	for _, test := range tests {
+		actual, err := ToHTMLInline([]byte(test.input))
+		if err != nil {
+			t.Errorf("error converting %q: %s", test.input, err)
+		} else if string(actual) != test.output {
+			t.Errorf("incorrect output for %q\nexpected:\n%s\nactual:\n%s", test.input, test.output, actual)
+		}
+	}
+}
+
+func TestParser(t *testing.T) {
+	options := Options{BaseURL: "http://example.com/a/", HeadingIDs: true, Footnotes: true}
+	inputs := []string{
+		"# foo\n\n[bar](baz)\n",
+		"a[^1]\n\n[^1]: b\n",
+		"[x]\n\n[x]: y\n",
+	}
+	parser := NewParser(options)
+	done := make(chan bool)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for _, input := range inputs {
+				expected, _ := ToHTMLBytesWithOptions([]byte(input), options)
+				actual, err := parser.ToHTMLBytes([]byte(input))
+				if err != nil {
+					t.Errorf("Parser.ToHTMLBytes(%q) returned error: %s", input, err)
+				} else if !bytes.Equal(actual, expected) {
+					t.Errorf("Parser.ToHTMLBytes(%q) = %q, expected %q", input, actual, expected)
+				}
+			}
+			done <- true
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+}
+
+func TestAppendHTML(t *testing.T) {
+	dst := make([]byte, 0, 64)
+	dst = append(dst, "<div>"...)
+	dst, err := AppendHTML(dst, []byte("*a*\n"))
+	if err != nil {
+		t.Fatalf("AppendHTML returned error: %s", err)
+	}
+	prefix := dst[:5]
+	dst, err = AppendHTML(dst, []byte("# b\n"))
+	if err != nil {
+		t.Fatalf("AppendHTML returned error: %s", err)
+	}
+	dst = append(dst, "</div>"...)
+	if expected := "<div><p><em>a</em></p>\n<h1>b</h1>\n</div>"; string(dst) != expected {
+		t.Errorf("AppendHTML gave %q, expected %q", dst, expected)
+	}
+	if &prefix[0] != &dst[0] {
+		t.Errorf("AppendHTML did not append in place, although there was room")
+	}
+}
+
+func TestEmptyOutput(t *testing.T) {
+	inputs := []string{"", "\n", "\n\n\n", "\r\n\r", "   \n\t\n  ", "\ufeff\n", "[foo]: /url\n"}
+	for _, input := range inputs {
+		if output, err := ToHTMLBytes([]byte(input)); err != nil || len(output) != 0 {
+			t.Errorf("ToHTMLBytes(%q) = %q, %v; expected empty output", input, output, err)
+		}
+		var output bytes.Buffer
+		if err := Convert(&output, strings.NewReader(input)); err != nil || output.Len() != 0 {
+			t.Errorf("Convert(%q) wrote %q, returned %v; expected empty output", input, output.Bytes(), err)
+		}
+	}
+}
+
+func TestToHTMLBytesMulti(t *testing.T) {
+	tests := []struct {
+		docs    []string
+		options Options
+		output  string
+	}{
+		// Footnotes are numbered on across documents, and their ids are kept
+		// unique.
+		{
+			[]string{"a[^x]\n\n[^x]: b\n", "c[^x] d[^y]\n\n[^x]: e\n[^y]: f\n"},
+			Options{Footnotes: true},
+			"<p>a<sup class=\"footnote-ref\"><a href=\"#fn-x\" id=\"fnref-x\">1</a></sup></p>\n" +
+				"<section class=\"footnotes\">\n<ol>\n<li id=\"fn-x\">\n" +
+				"<p>b <a href=\"#fnref-x\" class=\"footnote-backref\">↩</a></p>\n</li>\n</ol>\n</section>\n" +
+				"<p>c<sup class=\"footnote-ref\"><a href=\"#fn-x-1\" id=\"fnref-x-1\">2</a></sup> " +
+				"d<sup class=\"footnote-ref\"><a href=\"#fn-y\" id=\"fnref-y\">3</a></sup></p>\n" +
+				"<section class=\"footnotes\">\n<ol start=\"2\">\n<li id=\"fn-x-1\">\n" +
+				"<p>e <a href=\"#fnref-x-1\" class=\"footnote-backref\">↩</a></p>\n</li>\n" +
+				"<li id=\"fn-y\">\n<p>f <a href=\"#fnref-y\" class=\"footnote-backref\">↩</a></p>\n</li>\n</ol>\n</section>\n",
+		},
+		// Heading ids are unique across documents.
+		{
+			[]string{"# Foo\n", "# Foo\n\n# Foo\n"},
+			Options{HeadingIDs: true},
+			"<h1 id=\"foo\">Foo</h1>\n<h1 id=\"foo-1\">Foo</h1>\n<h1 id=\"foo-2\">Foo</h1>\n",
+		},
+		// Reference definitions are not shared by default.
+		{
+			[]string{"[a]\n\n[a]: /one\n", "[a]\n"},
+			Options{},
+			"<p><a href=\"/one\">a</a></p>\n<p>[a]</p>\n",
+		},
+		{
+			[]string{"[a]\n\n[a]: /one\n", "[a] [b]\n\n[b]: /two\n", "[a] [b]\n\n[a]: /three\n"},
+			Options{ShareReferenceDefinitions: true},
+			"<p><a href=\"/one\">a</a></p>\n" +
+				"<p><a href=\"/one\">a</a> <a href=\"/two\">b</a></p>\n" +
+				"<p><a href=\"/three\">a</a> <a href=\"/two\">b</a></p>\n",
+		},
+		{nil, Options{}, ""},
+	}
+	for _, test := range tests {
+		var docs [][]byte
+		for _, doc := range test.docs {
+			docs = append(docs, []byte(doc))
+		}
+		actual, err := ToHTMLBytesMulti(docs, test.options)
+		if err != nil {
+			t.Errorf("error converting %q: %s", test.docs, err)
+		} else if string(actual) != test.output {
+			t.Errorf("incorrect output for %q\nexpected:\n%s\nactual:\n%s", test.docs, test.output, actual)
+		}
+	}
+}
+
+// conversionTest is a single input/output pair for ToHTMLBytes.
+type conversionTest struct {
+	input  string
+	output string
+}
+
+func runConversionTests(t *testing.T, tests []conversionTest) {
+	runConversionTestsWithOptions(t, Options{}, tests)
+}
+
+func runConversionTestsWithOptions(t *testing.T, options Options, tests []conversionTest) {
+	for _, test := range tests {
+		actual, err := ToHTMLBytesWithOptions([]byte(test.input), options)
+		if err != nil {
+			t.Errorf("error converting %q: %s", test.input, err)
+		} else if string(actual) != test.output {
+			t.Errorf("incorrect output for %q\nexpected:\n%s\nactual:\n%s", test.input, test.output, actual)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	var input bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&input, "# header %d\n\nparagraph %d\nwith two lines\n\n    code %d\n\n", i, i, i)
+	}
+	expected, err := ToHTMLBytes(input.Bytes())
+	if err != nil {
+		t.Fatalf("ToHTMLBytes returned error: %s", err)
+	}
+
+	var output bytes.Buffer
+	if err := Convert(&output, iotest.OneByteReader(bytes.NewReader(input.Bytes()))); err != nil {
+		t.Fatalf("Convert returned error: %s", err)
+	}
+	if !bytes.Equal(output.Bytes(), expected) {
+		t.Errorf("Convert output differs from ToHTMLBytes output")
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	if err := Convert(ioutil.Discard, iotest.TimeoutReader(strings.NewReader("foo"))); err != iotest.ErrTimeout {
+		t.Errorf("expected read error %q, got %v", iotest.ErrTimeout, err)
+	}
+
+	if err := Convert(failingWriter{}, strings.NewReader("foo\n\nbar\n")); err != errWriteFailed {
+		t.Errorf("expected write error %q, got %v", errWriteFailed, err)
+	}
+}
+
+var errWriteFailed = errors.New("write failed")
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errWriteFailed
+}
+
+func TestInPlaceLines(t *testing.T) {
+	// Lines without tabs, NULs or CRs are used in place; the results must be
+	// the same as when they are copied, and the input must not be modified.
+	inputs := []string{
+		"# foo\n\nbar\nbaz\n",
+		"no final newline",
+		"- a\n- b\n\n      code\n> quote\n",
+		"mixed\ttabs\n\nand\r\nline\rendings\n\x00\n",
+		"| a | b |\n| - | - |\n| c |\n",
+	}
+	for _, input := range inputs {
+		data := []byte(input)
+		actual, err := ToHTMLBytesWithOptions(data, Options{Tables: true})
+		if err != nil {
+			t.Fatalf("ToHTMLBytesWithOptions(%q) returned error: %s", input, err)
+		}
+		if string(data) != input {
+			t.Errorf("ToHTMLBytesWithOptions modified its input %q to %q", input, data)
+		}
+		var expected bytes.Buffer
+		if err := convert(&expected, strings.NewReader(input), nil, &Options{Tables: true}); err != nil {
+			t.Fatalf("convert(%q) returned error: %s", input, err)
+		}
+		if !bytes.Equal(actual, expected.Bytes()) {
+			t.Errorf("output for %q differs when lines are used in place\nin place:\n%s\ncopied:\n%s", input, actual, expected.Bytes())
+		}
+	}
+}
+
+// benchmarkDocument returns a large document, whose code blocks are indented
+// with the given string.
+func benchmarkDocument(indent string) []byte {
+	var input bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&input, "# header %d\n\nparagraph %d\nwith *two* lines\n\n%scode %d\n\n- item\n- item\n\n", i, i, indent, i)
+	}
+	return input.Bytes()
+}
+
+func benchmarkToHTMLBytes(b *testing.B, input []byte) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		if _, err := ToHTMLBytes(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkToHTMLBytes converts a document without tabs, whose lines are used
+// in place.
+func BenchmarkToHTMLBytes(b *testing.B) {
+	benchmarkToHTMLBytes(b, benchmarkDocument("    "))
+}
+
+// BenchmarkToHTMLBytesWithTabs converts the same document with tabs, so that
+// some of the lines must be copied.
+func BenchmarkToHTMLBytesWithTabs(b *testing.B) {
+	benchmarkToHTMLBytes(b, benchmarkDocument("\t"))
+}
+
+// BenchmarkToHTMLBytesComment converts a document of the size of a typical
+// comment, for which the allocation of the output matters most.
+func BenchmarkToHTMLBytesComment(b *testing.B) {
+	input := []byte("Thanks! I tried **both** of these, and the [second one](https://example.com/a?b=c) works:\n\n" +
+		"```go\nfmt.Println(\"hello\")\n```\n\n- one\n- two\n\n> quoted *text*\n")
+	benchmarkToHTMLBytes(b, input)
+}
+
+func BenchmarkNewParser(b *testing.B) {
+	input := benchmarkDocument("  ")
+	options := Options{BaseURL: "https://example.com/", Tables: true}
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		if _, err := NewParser(options).ToHTMLBytes(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReuseParser(b *testing.B) {
+	input := benchmarkDocument("  ")
+	parser := NewParser(Options{BaseURL: "https://example.com/", Tables: true})
+	b.SetBytes(int64(len(input)))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := parser.ToHTMLBytes(input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package commonmark
+
+import (
+	"bytes"
+)
+
+// definitionList is a definition list, with the definition lists extension of
+// PHP Markdown Extra. Its children are definitionTerms and
+// definitionDescriptions: one or more terms, followed by one or more
+// descriptions of them, and so on.
+type definitionList struct {
+	block
+}
+
+func (l *definitionList) CanContain(b Block) bool {
+	switch b.(type) {
+	case *definitionTerm, *definitionDescription:
+		return true
+	}
+	return false
+}
+
+func (l *definitionList) Close() {
+	// Any blank lines after the last description do not count as part of
+	// the list.
+	if len(l.children) > 0 {
+		l.endLine = l.children[len(l.children)-1].base().endLine
+	}
+}
+
+// definitionTerm is a term in a definition list, which takes up a single line
+// of inline content.
+type definitionTerm struct {
+	block
+}
+
+// definitionDescription is the description of the preceding terms in a
+// definition list. It is a container block, like a list item: its first line
+// starts with a colon, and subsequent lines must be indented to line up with
+// its content.
+type definitionDescription struct {
+	block
+	// padding is the width of the colon, the spaces following it, and any
+	// indentation before it; continuation lines must be indented by this
+	// much.
+	padding int
+	// tight is false if the description is preceded by a blank line, or
+	// contains blocks separated by blank lines; its paragraphs are then
+	// wrapped in <p> tags.
+	tight bool
+}
+
+func (d *definitionDescription) CanContain(b Block) bool {
+	switch b.(type) {
+	case *listItem, *definitionTerm, *definitionDescription:
+		return false
+	}
+	return true
+}
+
+func (d *definitionDescription) Close() {
+	// As for list items, blank lines after the content do not count as part
+	// of the description.
+	if len(d.children) > 0 {
+		d.endLine = d.children[len(d.children)-1].base().endLine
+	} else {
+		d.endLine = d.startLine
+	}
+	for i := 0; i+1 < len(d.children); i++ {
+		if endsWithBlankLine(d.children[i], d.children[i+1]) {
+			d.tight = false
+		}
+	}
+}
+
+// parseDefinitionMarker recognizes the colon that starts a description in a
+// definition list, which may be indented by up to three spaces and must be
+// followed by at least one space and some content. It returns the padding of
+// the description, or 0 if the line does not start one.
+func parseDefinitionMarker(line []byte) int {
+	indent := indentation(line)
+	if indent > 3 || line[indent] != ':' {
+		return 0
+	}
+	spaces := indentation(line[indent+1:])
+	if spaces == 0 || isBlank(line[indent+1:]) {
+		return 0
+	}
+	if spaces > 4 {
+		// As in list items, the content is an indented code block, which
+		// starts one space after the colon.
+		spaces = 1
+	}
+	return indent + 1 + spaces
+}
+
+// startDefinitionDescription checks whether the line, which starts with a
+// marker of the given padding, starts a description in a definition list. If
+// the last matched block is a paragraph, or the last matched container ends
+// with one, the lines of the paragraph become the terms of a new definition
+// list, or of the definition list just before the paragraph. Otherwise, the
+// last matched container must be a definition list already. If so, the
+// description is opened and true is returned.
+func (p *blockParser) startDefinitionDescription(line []byte, padding int) bool {
+	var par *paragraph
+	if b, ok := p.openBlocks[p.lastMatched].(*paragraph); ok {
+		// The paragraph turns into terms, so it ends here.
+		p.closeLastBlock()
+		p.lastMatched--
+		if isBlank(b.content) {
+			// It consisted of link reference definitions only, and is
+			// gone.
+			return false
+		}
+		par = b
+	} else if p.lastMatched == len(p.openBlocks)-1 {
+		// The paragraph may have been ended by blank lines.
+		if children := p.openBlock().Children(); len(children) > 0 {
+			par, _ = children[len(children)-1].(*paragraph)
+		}
+	}
+	_, containerIsList := p.openBlocks[p.lastMatched].(*definitionList)
+	if par == nil && !containerIsList {
+		return false
+	}
+	p.closeUnmatchedBlocks()
+
+	container := p.openBlock()
+	var previous Block
+	if children := container.Children(); len(children) > 0 {
+		previous = children[len(children)-1]
+	}
+	if par != nil {
+		container.RemoveLastChild()
+		var list *definitionList
+		if children := container.Children(); len(children) > 0 {
+			list, _ = children[len(children)-1].(*definitionList)
+		}
+		if list != nil {
+			// Continue the definition list before the paragraph.
+			p.openBlocks = append(p.openBlocks, list)
+			p.lastMatched = len(p.openBlocks) - 1
+		} else {
+			list = &definitionList{}
+			p.addChild(list, par.startColumn)
+			list.startLine = par.startLine
+		}
+		appendDefinitionTerms(list, par)
+	}
+
+	// "If there is a blank line before a definition, Markdown will wrap the
+	// definition in <p> tags in the HTML output."
+	d := &definitionDescription{padding: padding}
+	d.tight = previous == nil || previous.base().endLine >= p.lineNumber-1
+	p.addChild(d, p.column(line)+indentation(line))
+	return true
+}
+
+// appendDefinitionTerms appends each line of the paragraph to the list as a
+// term.
+func appendDefinitionTerms(list *definitionList, par *paragraph) {
+	for i, l := range par.contentLines {
+		end := len(par.content)
+		if i+1 < len(par.contentLines) {
+			end = par.contentLines[i+1].offset
+		}
+		term := &definitionTerm{}
+		term.content = bytes.TrimRight(par.content[l.offset:end], " \n")
+		term.contentLines = []contentLine{{0, l.line, l.column}}
+		term.startLine, term.endLine, term.startColumn = l.line, l.line, l.column
+		list.AppendChild(term)
+	}
+}
@@ -0,0 +1,126 @@
+package commonmark
+
+import (
+	"bytes"
+)
+
+// EventKind is the kind of an Event.
+type EventKind int
+
+const (
+	// StartEvent is emitted for a node before the events of its children.
+	StartEvent EventKind = iota
+	// EndEvent is emitted for a node after the events of its children.
+	EndEvent
+)
+
+// Event is passed to the handler of ParseEvents for the start and end of each
+// node in the document.
+type Event struct {
+	// Kind tells whether the node starts or ends.
+	Kind EventKind
+	// Node is the node that starts or ends. It is the same Node for both
+	// events, but it is not part of a tree: it has no parent, siblings or
+	// children.
+	Node *Node
+}
+
+// ParseEvents parses text formatted in CommonMark, like ParseDocument, but
+// instead of returning a tree of nodes, it calls handler with a StartEvent and
+// an EndEvent for every node, in the same order as Walk would visit them. This
+// happens for all nodes, even those that cannot have children.
+//
+// The whole document is still parsed before the first event is emitted (for
+// example, link references may be defined after they are used), but no tree of
+// nodes is built, so nodes that are no longer needed can be garbage collected
+// as soon as the handler is done with them.
+//
+// If handler returns an error, no more events are emitted and the error is
+// returned.
+func ParseEvents(data []byte, handler func(ev Event) error) error {
+	return ParseEventsWithOptions(data, Options{}, handler)
+}
+
+// ParseEventsWithOptions is like ParseEvents, but allows the parsing to be
+// configured. Options that only affect rendering are ignored.
+func ParseEventsWithOptions(data []byte, options Options, handler func(ev Event) error) error {
+	doc, err := parse(bytes.NewReader(data), data, &options)
+	if err != nil {
+		return err
+	}
+	e := eventEmitter{&doc.sourceMap, handler}
+
+	n := newBlockNode(doc, e.sourceMap)
+	n.options = &options
+	n.lineEnding = doc.lineEnding
+	if err := handler(Event{StartEvent, n}); err != nil {
+		return err
+	}
+	if err := e.emitChildren(doc); err != nil {
+		return err
+	}
+	// As in documentToNode, footnote definitions come last.
+	for _, f := range doc.footnotes.referenced {
+		if err := e.emitBlock(f); err != nil {
+			return err
+		}
+	}
+	return handler(Event{EndEvent, n})
+}
+
+// eventEmitter emits the events for the internal representation of a
+// document.
+type eventEmitter struct {
+	sourceMap *sourceMap
+	handler   func(ev Event) error
+}
+
+// emitBlock emits the events for a block and everything in it.
+func (e *eventEmitter) emitBlock(b Block) error {
+	n := newBlockNode(b, e.sourceMap)
+	if err := e.handler(Event{StartEvent, n}); err != nil {
+		return err
+	}
+	if err := e.emitChildren(b); err != nil {
+		return err
+	}
+	return e.handler(Event{EndEvent, n})
+}
+
+// emitChildren emits the events for the child blocks or inline content of a
+// block, like blockToNode does.
+func (e *eventEmitter) emitChildren(b Block) error {
+	for _, child := range b.Children() {
+		if _, ok := child.(*footnoteDefinition); ok {
+			continue
+		}
+		if err := e.emitBlock(child); err != nil {
+			return err
+		}
+	}
+	if inlines := b.base().inlineContent; inlines != nil {
+		return e.emitInline(inlines, b.base())
+	}
+	return nil
+}
+
+// emitInline emits the events for an inline and everything in it, like
+// appendInlineNodes does.
+func (e *eventEmitter) emitInline(i Inline, b *block) error {
+	var n *Node
+	if _, ok := i.(*multipleInline); !ok {
+		n = newInlineNode(i, b, e.sourceMap)
+		if err := e.handler(Event{StartEvent, n}); err != nil {
+			return err
+		}
+	}
+	for _, child := range inlineChildren(i) {
+		if err := e.emitInline(child, b); err != nil {
+			return err
+		}
+	}
+	if n == nil {
+		return nil
+	}
+	return e.handler(Event{EndEvent, n})
+}
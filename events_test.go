@@ -0,0 +1,90 @@
+package commonmark
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// eventTrace returns the events for the given input, as in walkTrace, with the
+// literal content of nodes that have any.
+func eventTrace(t *testing.T, input string, options Options) string {
+	var trace []string
+	err := ParseEventsWithOptions([]byte(input), options, func(ev Event) error {
+		if ev.Node.Parent() != nil || ev.Node.FirstChild() != nil {
+			t.Errorf("node %s is part of a tree", ev.Node.Type)
+		}
+		s := ev.Node.Type.String()
+		if ev.Node.Literal() != nil {
+			s += fmt.Sprintf("%q", ev.Node.Literal())
+		}
+		if ev.Kind == StartEvent {
+			trace = append(trace, "+"+s)
+		} else {
+			trace = append(trace, "-"+s)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("ParseEvents returned error: %s", err)
+	}
+	return strings.Join(trace, " ")
+}
+
+func TestParseEvents(t *testing.T) {
+	input := "# *a*\n\n- b\n\n[c]: /url\n"
+	expected := `+Document +Heading +Emph +Text"a" -Text"a" -Emph -Heading ` +
+		`+List +Item +Paragraph +Text"b" -Text"b" -Paragraph -Item -List -Document`
+	if trace := eventTrace(t, input, Options{}); trace != expected {
+		t.Errorf("incorrect events for %q:\n%s\nexpected:\n%s", input, trace, expected)
+	}
+}
+
+func TestParseEventsMatchesWalk(t *testing.T) {
+	tests := []struct {
+		input   string
+		options Options
+	}{
+		{"> a [b](/c) `d`\n>\n>     e\n\n***\n", Options{}},
+		{"a[^1] b\n\n[^1]: *c*\n\n| d |\n| - |\n| e |\n", Options{Footnotes: true, Tables: true}},
+	}
+	for _, test := range tests {
+		root, err := ParseDocumentWithOptions([]byte(test.input), test.options)
+		if err != nil {
+			t.Fatalf("ParseDocument returned error: %s", err)
+		}
+		var trace []string
+		Walk(root, func(n *Node, entering bool) WalkStatus {
+			s := n.Type.String()
+			if n.Literal() != nil {
+				s += fmt.Sprintf("%q", n.Literal())
+			}
+			if entering {
+				trace = append(trace, "+"+s)
+			} else {
+				trace = append(trace, "-"+s)
+			}
+			return WalkContinue
+		})
+		expected := strings.Join(trace, " ")
+		if trace := eventTrace(t, test.input, test.options); trace != expected {
+			t.Errorf("incorrect events for %q:\n%s\nexpected:\n%s", test.input, trace, expected)
+		}
+	}
+}
+
+func TestParseEventsError(t *testing.T) {
+	stop := errors.New("stop")
+	count := 0
+	err := ParseEvents([]byte("a *b* c\n"), func(ev Event) error {
+		count++
+		if ev.Node.Type == Emph {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 5 {
+		t.Errorf("ParseEvents returned %v after %d events, expected %v after 5", err, count, stop)
+	}
+}
@@ -0,0 +1,119 @@
+package commonmark
+
+import (
+	"regexp"
+)
+
+// footnoteDefinition is the definition of a footnote, with the footnotes
+// extension. It is a container block, like a list item: its first line starts
+// with [^label]:, and subsequent lines must be indented by four spaces.
+type footnoteDefinition struct {
+	block
+	label []byte
+	// number is the 1-based number of the footnote, in order of first
+	// reference, or 0 if it has not been referenced.
+	number int
+	// numReferences is the number of references to the footnote.
+	numReferences int
+	// id identifies the footnote in the HTML ids of the footnote and its
+	// references. It is derived from the label, and set when the footnote is
+	// numbered.
+	id string
+}
+
+func (f *footnoteDefinition) CanContain(b Block) bool {
+	_, isListItem := b.(*listItem)
+	return !isListItem
+}
+
+func (f *footnoteDefinition) Close() {
+	// Any blank lines after the content of the footnote do not count as part
+	// of it.
+	if len(f.children) > 0 {
+		f.endLine = f.children[len(f.children)-1].base().endLine
+	} else {
+		f.endLine = f.startLine
+	}
+}
+
+// footnoteMap holds the footnote definitions of a document.
+type footnoteMap struct {
+	// definitions maps normalized labels to their definitions.
+	definitions map[string]*footnoteDefinition
+	// referenced holds the definitions that have been referenced, in order
+	// of first reference.
+	referenced []*footnoteDefinition
+	// offset is the number of footnotes numbered before this document, when
+	// several documents are converted together.
+	offset int
+	// ids holds the ids of the footnotes numbered so far, possibly in other
+	// documents.
+	ids slugSet
+}
+
+// define adds the footnote definition to the map, unless there already is a
+// definition with the same label.
+func (m *footnoteMap) define(f *footnoteDefinition) {
+	key := normalizeLabel(f.label)
+	if _, ok := m.definitions[key]; !ok {
+		m.definitions[key] = f
+	}
+}
+
+// reference returns the definition with the given label, numbering it if this
+// is its first reference. It returns nil if there is no such definition.
+func (m *footnoteMap) reference(label []byte) *footnoteDefinition {
+	f := m.definitions[normalizeLabel(label)]
+	if f == nil {
+		return nil
+	}
+	if f.number == 0 {
+		if m.ids == nil {
+			m.ids = slugSet{}
+		}
+		m.referenced = append(m.referenced, f)
+		f.number = m.offset + len(m.referenced)
+		f.id = m.ids.unique(string(normalizeURL(f.label)))
+	}
+	f.numReferences++
+	return f
+}
+
+// footnoteReference is a reference to a footnote.
+type footnoteReference struct {
+	span
+	definition *footnoteDefinition
+	// index is the 1-based number of this reference among the references to
+	// the same footnote.
+	index int
+}
+
+var footnoteDefinitionRe = regexp.MustCompile(`^ {0,3}\[\^([^\]\s]+)\]: *`)
+
+// parseFootnoteDefinitionStart recognizes the start of a footnote definition.
+// It returns the new (empty) block and the rest of the line following the
+// marker, or nil if the line does not start a footnote definition.
+func parseFootnoteDefinitionStart(line []byte) (*footnoteDefinition, []byte) {
+	m := footnoteDefinitionRe.FindSubmatch(line)
+	if m == nil {
+		return nil, nil
+	}
+	return &footnoteDefinition{label: m[1]}, line[len(m[0]):]
+}
+
+var footnoteReferenceRe = regexp.MustCompile(`^\[\^([^\]\s]+)\]`)
+
+// parseFootnoteReference parses a reference to a defined footnote at the start
+// of data. It returns the reference and the number of bytes consumed, which is
+// 0 if data does not start with a reference to a defined footnote.
+func parseFootnoteReference(data []byte, footnotes *footnoteMap) (*footnoteReference, int) {
+	m := footnoteReferenceRe.FindSubmatch(data)
+	if m == nil {
+		return nil, 0
+	}
+	f := footnotes.reference(m[1])
+	if f == nil {
+		return nil, 0
+	}
+	return &footnoteReference{definition: f, index: f.numReferences}, len(m[0])
+}
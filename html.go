@@ -1,65 +1,581 @@
 package commonmark
 
 import (
+	"bytes"
 	"fmt"
 	"io"
-	"log"
+	"net/url"
 )
 
-func blockToHTML(b Block, out io.Writer) {
-	// Why not simply a method on Block? Extensibility: we want to support
-	// other (pluggable) output types than HTML, and also custom Block types.
-	switch t := b.(type) {
-	case *document:
-		for _, child := range t.Children() {
-			blockToHTML(child, out)
-		}
-	case *horizontalRule:
-		io.WriteString(out, "<hr />\n")
-	case *atxHeader:
-		fmt.Fprintf(out, "<h%d>", t.level)
-		inlineToHTML(t.inlineContent, out)
-		fmt.Fprintf(out, "</h%d>\n", t.level)
-	case *indentedCodeBlock:
-		io.WriteString(out, "<pre><code>")
-		writeEscaped(t.content, out)
-		io.WriteString(out, "</code></pre>\n")
-	case *paragraph:
-		io.WriteString(out, "<p>")
-		inlineToHTML(t.inlineContent, out)
-		io.WriteString(out, "</p>\n")
-	case *blockQuote:
-		io.WriteString(out, "<blockquote>\n")
-		for _, child := range t.Children() {
-			blockToHTML(child, out)
-		}
-		io.WriteString(out, "</blockquote>\n")
+// htmlRenderer is the Renderer that produces HTML.
+type htmlRenderer struct {
+	options Options
+	// baseURL is the parsed Options.BaseURL, or nil if it is empty or
+	// invalid.
+	baseURL *url.URL
+}
+
+// NewHTMLRenderer returns a Renderer that produces HTML, configured by the
+// given options. A custom Renderer can delegate to it for the node types that
+// it does not handle itself.
+func NewHTMLRenderer(options Options) Renderer {
+	r := &htmlRenderer{options: options}
+	if options.BaseURL != "" {
+		r.baseURL, _ = url.Parse(options.BaseURL)
+	}
+	return r
+}
+
+// endBlock writes the newline that follows every block, except the last block
+// in a list item or definition description. This way, the blocks in a list
+// item are separated by newlines, but there is no newline before the closing
+// tag of the item.
+func endBlock(n *Node, out io.Writer) {
+	if n.next == nil && n.parent != nil && (n.parent.Type == Item || n.parent.Type == DefinitionDescription) {
+		return
+	}
+	io.WriteString(out, "\n")
+}
+
+func (r *htmlRenderer) RenderNode(out io.Writer, n *Node, entering bool) (WalkStatus, error) {
+	options := &r.options
+	if lineEnding := r.lineEnding(n); lineEnding != "\n" {
+		out = &lineEndingWriter{w: out, lineEnding: lineEnding}
+	}
+	switch n.Type {
+	case Document:
+		if tag := options.wrapperTag(); tag != "" && entering {
+			io.WriteString(out, "<"+tag)
+			if options.WrapperClass != "" {
+				io.WriteString(out, ` class="`)
+				writeEscaped([]byte(options.WrapperClass), out)
+				io.WriteString(out, `"`)
+			}
+			io.WriteString(out, ">\n")
+		} else if tag != "" {
+			io.WriteString(out, "</"+tag+">\n")
+		}
+	case HorizontalRule:
+		if entering {
+			io.WriteString(out, "<hr"+r.voidEnd())
+			endBlock(n, out)
+		}
+	case Heading:
+		if entering && n.id != "" {
+			fmt.Fprintf(out, "<h%d id=\"", n.level)
+			writeEscaped([]byte(n.id), out)
+			io.WriteString(out, `">`)
+		} else if entering {
+			fmt.Fprintf(out, "<h%d>", n.level)
+		} else {
+			fmt.Fprintf(out, "</h%d>", n.level)
+			endBlock(n, out)
+		}
+	case CodeBlock:
+		if !entering {
+			break
+		}
+		if attrs := parseCodeAttributes(n.info); attrs != nil && options.FencedCodeAttributes {
+			io.WriteString(out, "<pre><code")
+			if attrs.id != nil {
+				io.WriteString(out, ` id="`)
+				writeEscaped(attrs.id, out)
+				io.WriteString(out, `"`)
+			}
+			if attrs.classes != nil {
+				io.WriteString(out, ` class="`)
+				writeEscaped(bytes.Join(attrs.classes, []byte{' '}), out)
+				io.WriteString(out, `"`)
+			}
+			io.WriteString(out, ">")
+		} else if fields := bytes.Fields(n.info); len(fields) > 0 {
+			io.WriteString(out, `<pre><code class="`)
+			writeEscaped([]byte(options.languagePrefix()), out)
+			writeEscaped(fields[0], out)
+			io.WriteString(out, `">`)
+		} else {
+			io.WriteString(out, "<pre><code>")
+		}
+		writeEscaped(n.literal, out)
+		io.WriteString(out, "</code></pre>")
+		endBlock(n, out)
+	case HTMLBlock:
+		if !entering {
+			break
+		}
+		if options.Safe {
+			io.WriteString(out, "<!-- raw HTML omitted -->")
+		} else {
+			r.writeRawHTML(bytes.TrimSuffix(n.literal, []byte{'\n'}), out)
+		}
+		endBlock(n, out)
+	case FootnoteDefinition:
+		if entering {
+			if n.prev == nil || n.prev.Type != FootnoteDefinition {
+				io.WriteString(out, "<section class=\"footnotes\">\n")
+				// Footnotes are numbered on from earlier documents by
+				// ToHTMLBytesMulti.
+				if n.footnoteNumber > 1 {
+					fmt.Fprintf(out, "<ol start=\"%d\">\n", n.footnoteNumber)
+				} else {
+					io.WriteString(out, "<ol>\n")
+				}
+			}
+			io.WriteString(out, `<li id="fn-`)
+			writeEscaped([]byte(n.id), out)
+			io.WriteString(out, "\">\n")
+			break
+		}
+		// The back references are added to the last paragraph, or get their
+		// own paragraph if there is none.
+		if n.lastChild == nil || n.lastChild.Type != Paragraph {
+			io.WriteString(out, "<p>")
+			writeFootnoteBackReferences(n, out)
+			io.WriteString(out, "</p>\n")
+		}
+		io.WriteString(out, "</li>\n")
+		if n.next == nil || n.next.Type != FootnoteDefinition {
+			io.WriteString(out, "</ol>\n</section>")
+			endBlock(n, out)
+		}
+	case Paragraph:
+		item := n.parent
+		if item != nil && item.Type != Item {
+			item = nil
+		}
+		// In tight lists and definition descriptions, the paragraphs are
+		// written without <p> tags.
+		tight := item != nil && item.parent != nil && item.parent.tight ||
+			n.parent != nil && n.parent.Type == DefinitionDescription && n.parent.tight
+		if !entering {
+			if f := n.parent; f != nil && f.Type == FootnoteDefinition && n.next == nil {
+				io.WriteString(out, " ")
+				writeFootnoteBackReferences(f, out)
+			}
+			if !tight {
+				io.WriteString(out, "</p>")
+			}
+			endBlock(n, out)
+			break
+		}
+		if !tight {
+			io.WriteString(out, "<p>")
+		}
+		if item != nil && item.task && n.prev == nil {
+			if item.checked {
+				io.WriteString(out, `<input type="checkbox" checked="" disabled=""`+r.voidEnd()+" ")
+			} else {
+				io.WriteString(out, `<input type="checkbox" disabled=""`+r.voidEnd()+" ")
+			}
+		}
+	case BlockQuote:
+		if entering {
+			io.WriteString(out, "<blockquote>\n")
+		} else {
+			io.WriteString(out, "</blockquote>")
+			endBlock(n, out)
+		}
+	case List:
+		tag := "ul"
+		if n.Ordered() {
+			tag = "ol"
+		}
+		if !entering {
+			fmt.Fprintf(out, "</%s>", tag)
+			endBlock(n, out)
+		} else if n.Ordered() && n.Start() != 1 {
+			fmt.Fprintf(out, "<%s start=\"%d\">\n", tag, n.Start())
+		} else {
+			fmt.Fprintf(out, "<%s>\n", tag)
+		}
+	case Item:
+		if entering {
+			io.WriteString(out, "<li>")
+		} else {
+			io.WriteString(out, "</li>\n")
+		}
+	case DefinitionList:
+		if entering {
+			io.WriteString(out, "<dl>\n")
+		} else {
+			io.WriteString(out, "</dl>")
+			endBlock(n, out)
+		}
+	case DefinitionTerm:
+		if entering {
+			io.WriteString(out, "<dt>")
+		} else {
+			io.WriteString(out, "</dt>\n")
+		}
+	case DefinitionDescription:
+		if entering {
+			io.WriteString(out, "<dd>")
+		} else {
+			io.WriteString(out, "</dd>\n")
+		}
+	case Table:
+		if entering {
+			io.WriteString(out, "<table>\n")
+			break
+		}
+		if n.lastChild != nil && n.lastChild.Type == TableRow {
+			io.WriteString(out, "</tbody>\n")
+		}
+		io.WriteString(out, "</table>")
+		endBlock(n, out)
+	case TableHeader:
+		if entering {
+			io.WriteString(out, "<thead>\n<tr>\n")
+		} else {
+			io.WriteString(out, "</tr>\n</thead>\n")
+		}
+	case TableRow:
+		if entering {
+			if n.prev == nil || n.prev.Type == TableHeader {
+				io.WriteString(out, "<tbody>\n")
+			}
+			io.WriteString(out, "<tr>\n")
+		} else {
+			io.WriteString(out, "</tr>\n")
+		}
+	case TableCell:
+		tag := "td"
+		if n.parent != nil && n.parent.Type == TableHeader {
+			tag = "th"
+		}
+		if !entering {
+			fmt.Fprintf(out, "</%s>\n", tag)
+		} else if n.alignment != "" {
+			fmt.Fprintf(out, "<%s align=\"%s\">", tag, n.alignment)
+		} else {
+			fmt.Fprintf(out, "<%s>", tag)
+		}
+	case Text:
+		if entering {
+			out.Write(escapeHTML(n.literal))
+		}
+	case Emph:
+		if entering {
+			io.WriteString(out, "<em>")
+		} else {
+			io.WriteString(out, "</em>")
+		}
+	case Strong:
+		if entering {
+			io.WriteString(out, "<strong>")
+		} else {
+			io.WriteString(out, "</strong>")
+		}
+	case Strikethrough:
+		if entering {
+			io.WriteString(out, "<del>")
+		} else {
+			io.WriteString(out, "</del>")
+		}
+	case Link:
+		if !entering {
+			io.WriteString(out, "</a>")
+			break
+		}
+		io.WriteString(out, `<a href="`)
+		r.writeURL(n.destination, out)
+		if len(n.title) > 0 {
+			io.WriteString(out, `" title="`)
+			writeEscaped(n.title, out)
+		}
+		if (options.NofollowExternal || options.ExternalLinksNewWindow) &&
+			isExternalURL(normalizeURL(n.destination), options.InternalHost) {
+			if options.NofollowExternal {
+				io.WriteString(out, `" rel="nofollow noopener`)
+			} else {
+				io.WriteString(out, `" rel="noopener`)
+			}
+			if options.ExternalLinksNewWindow {
+				io.WriteString(out, `" target="_blank`)
+			}
+		}
+		io.WriteString(out, `">`)
+	case Image:
+		if !entering {
+			break
+		}
+		io.WriteString(out, `<img src="`)
+		r.writeURL(n.destination, out)
+		io.WriteString(out, `" alt="`)
+		for child := n.firstChild; child != nil; child = child.next {
+			altTextToHTML(child, out)
+		}
+		if len(n.title) > 0 {
+			io.WriteString(out, `" title="`)
+			writeEscaped(n.title, out)
+		}
+		io.WriteString(out, `"`+r.voidEnd())
+		// The children have been written as the alt text already.
+		return WalkSkipChildren, nil
+	case FootnoteReference:
+		if !entering {
+			break
+		}
+		io.WriteString(out, `<sup class="footnote-ref"><a href="#fn-`)
+		writeEscaped([]byte(n.id), out)
+		io.WriteString(out, `" id="`)
+		writeFootnoteReferenceID(n.id, n.footnoteIndex, out)
+		fmt.Fprintf(out, `">%d</a></sup>`, n.footnoteNumber)
+	case SoftBreak:
+		if !entering {
+			break
+		}
+		switch {
+		case options.HardWraps:
+			io.WriteString(out, "<br"+r.voidEnd()+"\n")
+		case options.SoftBreak != "":
+			io.WriteString(out, options.SoftBreak)
+		default:
+			io.WriteString(out, "\n")
+		}
+	case HardBreak:
+		if entering {
+			io.WriteString(out, "<br"+r.voidEnd()+"\n")
+		}
+	case Code:
+		if entering {
+			io.WriteString(out, "<code>")
+			writeEscaped(n.literal, out)
+			io.WriteString(out, "</code>")
+		}
+	case Math:
+		// The same markup as Pandoc's, which MathJax and KaTeX recognize.
+		if entering && n.display {
+			io.WriteString(out, `<span class="math display">\[`)
+			writeEscaped(n.literal, out)
+			io.WriteString(out, `\]</span>`)
+		} else if entering {
+			io.WriteString(out, `<span class="math inline">\(`)
+			writeEscaped(n.literal, out)
+			io.WriteString(out, `\)</span>`)
+		}
+	case HTMLInline:
+		if !entering {
+			break
+		}
+		if options.Safe {
+			io.WriteString(out, "<!-- raw HTML omitted -->")
+		} else {
+			r.writeRawHTML(n.literal, out)
+		}
 	default:
-		log.Panicf("no HTML converter registered for Block type %T", b)
+		return WalkStop, fmt.Errorf("commonmark: no HTML renderer for node type %s", n.Type)
+	}
+	return WalkContinue, nil
+}
+
+// voidEnd returns the end of the tag of a void element such as <br />, which
+// is self-closing unless Options.HTML5 is enabled.
+func (r *htmlRenderer) voidEnd() string {
+	if r.options.HTML5 {
+		return ">"
+	}
+	return " />"
+}
+
+// lineEnding returns the line ending to use in the output for the given node.
+func (r *htmlRenderer) lineEnding(n *Node) string {
+	switch r.options.LineEnding {
+	case LineEndingCRLF:
+		return "\r\n"
+	case LineEndingPreserve:
+		for n.parent != nil {
+			n = n.parent
+		}
+		if n.lineEnding != "" {
+			return n.lineEnding
+		}
+	}
+	return "\n"
+}
+
+// lineEndingWriter wraps an io.Writer and replaces '\n' bytes by another line
+// ending.
+type lineEndingWriter struct {
+	w          io.Writer
+	lineEnding string
+}
+
+// Write writes p, with its line endings replaced, to the underlying writer. It
+// returns len(p) if all of it was written.
+func (l *lineEndingWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			n, err := l.w.Write(p)
+			return written + n, err
+		}
+		if n, err := l.w.Write(p[:i]); err != nil {
+			return written + n, err
+		}
+		if _, err := io.WriteString(l.w, l.lineEnding); err != nil {
+			return written + i, err
+		}
+		written += i + 1
+		p = p[i+1:]
+	}
+	return written, nil
+}
+
+// writeFootnoteReferenceID writes the id of the reference with the given
+// index to the footnote with the given id.
+func writeFootnoteReferenceID(id string, index int, out io.Writer) {
+	io.WriteString(out, "fnref-")
+	writeEscaped([]byte(id), out)
+	if index > 1 {
+		fmt.Fprintf(out, "-%d", index)
 	}
 }
 
-func inlineToHTML(i Inline, out io.Writer) {
-	switch t := i.(type) {
-	case *stringInline:
-		writeEscaped(t.content, out)
-	case *multipleInline:
-		for _, child := range t.children {
-			inlineToHTML(child, out)
+// writeFootnoteBackReferences writes the links from a footnote definition back
+// to each of its references.
+func writeFootnoteBackReferences(n *Node, out io.Writer) {
+	for i := 1; i <= n.numReferences; i++ {
+		if i > 1 {
+			io.WriteString(out, " ")
 		}
-	case *softLineBreak:
+		io.WriteString(out, `<a href="#`)
+		writeFootnoteReferenceID(n.id, i, out)
+		io.WriteString(out, `" class="footnote-backref">↩`)
+		if i > 1 {
+			fmt.Fprintf(out, "<sup>%d</sup>", i)
+		}
+		io.WriteString(out, "</a>")
+	}
+}
+
+// codeAttributes holds the attributes of a fenced code block that are given
+// in its info string, with Options.FencedCodeAttributes.
+type codeAttributes struct {
+	id      []byte
+	classes [][]byte
+}
+
+// parseCodeAttributes parses an info string that consists of a pandoc-style
+// attribute block, such as {#id .class key="value"}. Attributes other than
+// the id and the classes are ignored. It returns nil if the info string is
+// not an attribute block.
+func parseCodeAttributes(info []byte) *codeAttributes {
+	info = bytes.TrimSpace(info)
+	if len(info) < 2 || info[0] != '{' || info[len(info)-1] != '}' {
+		return nil
+	}
+	attrs := &codeAttributes{}
+	rest := info[1 : len(info)-1]
+	for {
+		rest = bytes.TrimLeft(rest, " \t")
+		if len(rest) == 0 {
+			return attrs
+		}
+		// An attribute ends at the first space that is not between quotes.
+		end := 0
+		var quote byte
+		for ; end < len(rest) && (quote != 0 || (rest[end] != ' ' && rest[end] != '\t')); end++ {
+			switch {
+			case quote == 0 && (rest[end] == '"' || rest[end] == '\''):
+				quote = rest[end]
+			case rest[end] == quote:
+				quote = 0
+			}
+		}
+		attr := rest[:end]
+		rest = rest[end:]
+		switch {
+		case len(attr) > 1 && attr[0] == '.':
+			attrs.classes = append(attrs.classes, attr[1:])
+		case len(attr) > 1 && attr[0] == '#':
+			attrs.id = attr[1:]
+		}
+	}
+}
+
+// writeURL writes the normalized and escaped URL for use in an attribute,
+// resolved against the base URL if there is one. In safe mode, nothing is
+// written for potentially dangerous URLs.
+func (r *htmlRenderer) writeURL(url []byte, out io.Writer) {
+	if r.options.Safe && isDangerousURL(url) {
+		return
+	}
+	url = normalizeURL(url)
+	if r.baseURL != nil {
+		url = resolveURL(url, r.baseURL)
+	}
+	writeEscaped(url, out)
+}
+
+// altTextToHTML writes the plain text content of an inline node, for use in
+// the alt attribute of an image.
+//
+// "Though this spec is concerned with parsing, not rendering, it is
+// recommended that in rendering to HTML, only the plain string content of the
+// image description be used."
+func altTextToHTML(n *Node, out io.Writer) {
+	switch n.Type {
+	case Text:
+		out.Write(escapeHTML(n.literal))
+	case Code, HTMLInline, Math:
+		writeEscaped(n.literal, out)
+	case SoftBreak, HardBreak:
 		io.WriteString(out, "\n")
-	case *hardLineBreak:
-		io.WriteString(out, "<br />\n")
-	case *codeSpan:
-		io.WriteString(out, "<code>")
-		writeEscaped(t.content, out)
-		io.WriteString(out, "</code>")
 	default:
-		log.Panicf("no HTML converter registered for Inline type %T", i)
+		for child := n.firstChild; child != nil; child = child.next {
+			altTextToHTML(child, out)
+		}
 	}
 }
 
+// filteredTags are the tags that Options.TagFilter disables.
+var filteredTags = []string{
+	"title", "textarea", "style", "xmp", "iframe", "noembed", "noframes", "script", "plaintext",
+}
+
+// writeRawHTML writes raw HTML as it is, except that with Options.TagFilter,
+// the < of any of the filteredTags is escaped.
+func (r *htmlRenderer) writeRawHTML(data []byte, out io.Writer) {
+	if !r.options.TagFilter {
+		out.Write(data)
+		return
+	}
+	var start int
+	for i, c := range data {
+		if c == '<' && isFilteredTag(data[i+1:]) {
+			out.Write(data[start:i])
+			io.WriteString(out, "&lt;")
+			start = i + 1
+		}
+	}
+	out.Write(data[start:])
+}
+
+// isFilteredTag returns whether data, which follows a <, starts with the
+// opening or closing tag of one of the filteredTags.
+func isFilteredTag(data []byte) bool {
+	data = bytes.TrimPrefix(data, []byte{'/'})
+	for _, tag := range filteredTags {
+		if len(data) < len(tag) || !bytes.EqualFold(data[:len(tag)], []byte(tag)) {
+			continue
+		}
+		// The tag name must end here.
+		rest := data[len(tag):]
+		if len(rest) == 0 {
+			return true
+		}
+		switch rest[0] {
+		case '>', ' ', '\t', '\n', '\v', '\f', '\r':
+			return true
+		case '/':
+			return len(rest) > 1 && rest[1] == '>'
+		}
+	}
+	return false
+}
+
 var escapeMap = map[byte]string{
 	'"': "&quot;",
 	'&': "&amp;",
@@ -82,3 +598,36 @@ func writeEscaped(data []byte, out io.Writer) {
 	}
 	out.Write(data[start:])
 }
+
+// escapeHTML returns a copy of data (or data itself, if nothing needs to be
+// escaped) in which the characters that are special in HTML have been
+// replaced by entities. Unlike writeEscaped, it leaves '&' alone if it starts
+// a valid entity or numeric character reference.
+//
+// The inline parser decodes all valid references in text, so a text node only
+// contains one if it was escaped, for example "\&amp;". In that case the '&'
+// ends up in a separate node, and is still escaped.
+func escapeHTML(data []byte) []byte {
+	var output []byte
+	var start int
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		escaped, ok := escapeMap[c]
+		if !ok {
+			continue
+		}
+		if c == '&' {
+			if _, length := parseEntity(data[i:]); length > 0 {
+				i += length - 1
+				continue
+			}
+		}
+		output = append(output, data[start:i]...)
+		output = append(output, escaped...)
+		start = i + 1
+	}
+	if output == nil {
+		return data
+	}
+	return append(output, data[start:]...)
+}
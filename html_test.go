@@ -0,0 +1,41 @@
+package commonmark
+
+import (
+	"testing"
+)
+
+func TestEscapeHTML(t *testing.T) {
+	tests := []struct {
+		input, output string
+	}{
+		{"", ""},
+		{"plain text", "plain text"},
+		{"<b>", "&lt;b&gt;"},
+		{`say "hi"`, "say &quot;hi&quot;"},
+		{"AT&T", "AT&amp;T"},
+		{"&amp;", "&amp;"},
+		{"&copy; &#35; &#x22;", "&copy; &#35; &#x22;"},
+		{"&foo;", "&amp;foo;"},
+		{"&copy", "&amp;copy"},
+		{"& &amp &amp;", "&amp; &amp;amp &amp;"},
+		{"&#;", "&amp;#;"},
+		{"a < b && c > d", "a &lt; b &amp;&amp; c &gt; d"},
+	}
+	for _, test := range tests {
+		actual := string(escapeHTML([]byte(test.input)))
+		if actual != test.output {
+			t.Errorf("escapeHTML(%q) = %q, expected %q", test.input, actual, test.output)
+		}
+	}
+}
+
+func TestTextEscaping(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"a < b > c & \"d\"\n", "<p>a &lt; b &gt; c &amp; &quot;d&quot;</p>\n"},
+		{"# <b>\n", "<h1><b></h1>\n"},
+		{"# < b>\n", "<h1>&lt; b&gt;</h1>\n"},
+		{"&amp; &copy;\n", "<p>&amp; ©</p>\n"},
+		{"\\&amp;\n", "<p>&amp;amp;</p>\n"},
+		{"`&amp;`\n", "<p><code>&amp;amp;</code></p>\n"},
+	})
+}
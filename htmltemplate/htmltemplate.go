@@ -0,0 +1,31 @@
+// Package htmltemplate converts CommonMark to HTML that can be used in
+// html/template templates as it is. It is a separate package so that programs
+// that do not use html/template do not depend on it.
+package htmltemplate
+
+import (
+	"html/template"
+
+	"github.com/ttencate/commonmark"
+)
+
+// ToTemplateHTML converts text formatted in CommonMark into HTML, like
+// commonmark.ToHTMLBytes, and returns it as template.HTML, which html/template
+// inserts without escaping it.
+//
+// Because template.HTML is trusted, do not use this for untrusted input, which
+// may contain raw HTML such as <script> tags, unless it is sanitized; or use
+// ToTemplateHTMLWithOptions with commonmark.Options.Safe.
+func ToTemplateHTML(markdown []byte) (template.HTML, error) {
+	return ToTemplateHTMLWithOptions(markdown, commonmark.Options{})
+}
+
+// ToTemplateHTMLWithOptions is like ToTemplateHTML, but allows the conversion
+// to be configured, like commonmark.ToHTMLBytesWithOptions.
+func ToTemplateHTMLWithOptions(markdown []byte, options commonmark.Options) (template.HTML, error) {
+	html, err := commonmark.ToHTMLBytesWithOptions(markdown, options)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(html), nil
+}
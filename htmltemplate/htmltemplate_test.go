@@ -0,0 +1,35 @@
+package htmltemplate
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+
+	"github.com/ttencate/commonmark"
+)
+
+func TestToTemplateHTML(t *testing.T) {
+	tmpl := template.Must(template.New("").Parse(`<div title="{{.Title}}">{{.Body}}</div>`))
+	body, err := ToTemplateHTML([]byte("# A & B\n\n*<br>*\n"))
+	if err != nil {
+		t.Fatalf("ToTemplateHTML returned error: %s", err)
+	}
+	var output bytes.Buffer
+	if err := tmpl.Execute(&output, map[string]interface{}{"Title": "<x>", "Body": body}); err != nil {
+		t.Fatalf("template returned error: %s", err)
+	}
+	expected := "<div title=\"&lt;x&gt;\"><h1>A &amp; B</h1>\n<p><em><br></em></p>\n</div>"
+	if output.String() != expected {
+		t.Errorf("template output is\n%s\nexpected\n%s", output.String(), expected)
+	}
+}
+
+func TestToTemplateHTMLWithOptions(t *testing.T) {
+	html, err := ToTemplateHTMLWithOptions([]byte("<script>x</script>\n"), commonmark.Options{Safe: true})
+	if err != nil {
+		t.Fatalf("ToTemplateHTMLWithOptions returned error: %s", err)
+	}
+	if expected := template.HTML("<!-- raw HTML omitted -->\n"); html != expected {
+		t.Errorf("ToTemplateHTMLWithOptions returned %q, expected %q", html, expected)
+	}
+}
@@ -2,27 +2,89 @@ package commonmark
 
 import (
 	"bytes"
-	"fmt"
+	"container/list"
 	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Inline interface {
+	// base returns the common part of the inline.
+	base() *span
+}
+
+// span implements the common part of the Inline interface: the range of the
+// inline content that the inline was parsed from.
+type span struct {
+	// start and end are the offsets of the first byte and just past the last
+	// byte of the inline.
+	start, end int
+}
+
+func (s *span) base() *span {
+	return s
 }
 
 type stringInline struct {
+	span
 	content []byte
 }
 
-type softLineBreak struct{}
+type softLineBreak struct {
+	span
+}
 
-type hardLineBreak struct{}
+type hardLineBreak struct {
+	span
+}
 
 type codeSpan struct {
+	span
 	content []byte
 }
 
+// rawHTML is an inline HTML tag, which is written to the output as-is.
+type rawHTML struct {
+	span
+	content []byte
+}
+
+type emphasis struct {
+	span
+	children []Inline
+}
+
+type strongEmphasis struct {
+	span
+	children []Inline
+}
+
+// link is an inline link. Its children form the link text.
+// strikethrough is text wrapped in ~~, with the strikethrough extension.
+type strikethrough struct {
+	span
+	children []Inline
+}
+
+type link struct {
+	span
+	children    []Inline
+	destination []byte
+	title       []byte
+}
+
+// image is an inline image. Its children form the image description, which is
+// used as the alt text.
+type image struct {
+	span
+	children    []Inline
+	destination []byte
+	title       []byte
+}
+
 type multipleInline struct {
+	span
 	children []Inline
 }
 
@@ -30,27 +92,83 @@ type inlineParser struct {
 	data        []byte
 	pos         int
 	stringStart int
+	references  referenceMap
+	footnotes   *footnoteMap
+	options     *Options
 
-	root *multipleInline
+	// inlines is the list of inlines parsed so far. It is a linked list
+	// because processing emphasis moves ranges of inlines into new parents.
+	inlines *list.List
+	// lastDelimiter is the top of the delimiter stack.
+	lastDelimiter *delimiter
+	// lastBracket is the top of the stack of potential link openers.
+	lastBracket *bracket
 }
 
-func parseInlines(data []byte) Inline {
+// delimiter is an entry on the delimiter stack: a run of * or _ characters
+// that might open or close emphasis, or (with the corresponding options) a run
+// of ~~ that might open or close strikethrough, or a quote.
+type delimiter struct {
+	// element is the element of inlineParser.inlines holding the
+	// *stringInline of the delimiter run.
+	element *list.Element
+
+	char      byte
+	count     int
+	origCount int
+	canOpen   bool
+	canClose  bool
+
+	prev, next *delimiter
+}
+
+// bracket is an entry on the bracket stack: a [ or ![ that might open a link
+// or image.
+type bracket struct {
+	// element is the element of inlineParser.inlines holding the
+	// *stringInline of the bracket.
+	element *list.Element
+
+	// pos is the position of the bracket in the input.
+	pos int
+	// image is true if the bracket was preceded by a !.
+	image bool
+	// active is false if the bracket can no longer open a link, because it
+	// would contain another link.
+	active bool
+	// bracketAfter is true if another [ was encountered after this one.
+	bracketAfter bool
+	// prevDelimiter is the top of the delimiter stack at the time the bracket
+	// was encountered.
+	prevDelimiter *delimiter
+	// depth is the number of brackets on the stack, including this one.
+	depth int
+
+	prev *bracket
+}
+
+func parseInlines(data []byte, references referenceMap, footnotes *footnoteMap, options *Options) Inline {
 	// I can't find where the spec decrees this. But the reference
 	// implementation does it this way:
 	// https://github.com/jgm/CommonMark/blob/67619a5d5c71c44565a9a0413aaf78f9baece528/src/inlines.c#L183
 	data = bytes.TrimRightFunc(data, unicode.IsSpace)
 
 	parser := inlineParser{
-		data: data,
-		root: &multipleInline{},
+		data:       data,
+		references: references,
+		footnotes:  footnotes,
+		options:    options,
+		inlines:    list.New(),
 	}
 	parser.parse()
-	return parser.root
+	parser.processEmphasis(nil)
+	return &multipleInline{span{0, len(data)}, listToSlice(parser.inlines)}
 }
 
 func (p *inlineParser) parse() {
 	for p.pos < len(p.data) {
 		var inline Inline
+		start := p.pos
 		switch p.data[p.pos] {
 		case '\n':
 			hardBreak := false
@@ -61,23 +179,17 @@ func (p *inlineParser) parse() {
 				hardBreak = true
 				p.pos -= 2
 			}
-			// "For a more visible alternative, a backslash before the newline
-			// may be used instead of two spaces."
-			if p.pos >= 1 && p.data[p.pos-1] == '\\' {
-				hardBreak = true
-				p.pos--
-			}
-
 			// "Spaces at the end of the line [...] are removed."
 			for p.pos > 0 && p.data[p.pos-1] == ' ' {
 				p.pos--
 			}
 			p.finalizeString()
 
+			lineBreak := span{p.pos, newlinePos + 1}
 			if hardBreak {
-				inline = &hardLineBreak{}
+				inline = &hardLineBreak{lineBreak}
 			} else {
-				inline = &softLineBreak{}
+				inline = &softLineBreak{lineBreak}
 			}
 
 			p.pos = newlinePos + 1
@@ -94,6 +206,10 @@ func (p *inlineParser) parse() {
 			for p.pos+numBackticks < len(p.data) && p.data[p.pos+numBackticks] == '`' {
 				numBackticks++
 			}
+			if p.options.disabled(CodeSpans) {
+				p.pos += numBackticks
+				break
+			}
 			closing := backtickStringIndex(p.data, p.pos+numBackticks, numBackticks)
 			if closing == -1 {
 				p.pos += numBackticks
@@ -103,13 +219,43 @@ func (p *inlineParser) parse() {
 			p.finalizeString()
 			p.pos += numBackticks
 
-			content := p.data[p.pos:closing]
-			content = collapseSpace(bytes.TrimSpace(content))
+			content := codeSpanContent(p.data[p.pos:closing])
 
-			inline = &codeSpan{content}
+			inline = &codeSpan{content: content}
 			p.pos = closing + numBackticks
 			p.resetString()
+		case '$':
+			if !p.options.Math {
+				p.pos++
+				break
+			}
+			m, length := parseMath(p.data[p.pos:])
+			if length == 0 {
+				// A run of dollar signs that does not start math is text, so
+				// that $$ cannot start inline math at its second $.
+				for p.pos < len(p.data) && p.data[p.pos] == '$' {
+					p.pos++
+				}
+				break
+			}
+			p.finalizeString()
+			inline = m
+			p.pos += length
+			p.resetString()
 		case '\\':
+			// "For a more visible alternative, a backslash before the newline
+			// may be used instead of two spaces."
+			if p.pos+1 < len(p.data) && p.data[p.pos+1] == '\n' {
+				p.finalizeString()
+				inline = &hardLineBreak{span{p.pos, p.pos + 2}}
+				p.pos += 2
+				for p.pos < len(p.data) && p.data[p.pos] == ' ' {
+					p.pos++
+				}
+				p.resetString()
+				break
+			}
+
 			// "Backslashes before other characters are treated as literal backslashes."
 			if p.pos+1 >= len(p.data) || !isASCIIPunct(p.data[p.pos+1]) {
 				p.pos++
@@ -119,77 +265,654 @@ func (p *inlineParser) parse() {
 			// "Any ASCII punctuation character may be backslash-escaped."
 			p.finalizeString()
 			p.pos++
-			inline = &stringInline{p.data[p.pos : p.pos+1]}
+			inline = &stringInline{content: p.data[p.pos : p.pos+1]}
 			p.pos++
 			p.resetString()
-		case '&':
-			// "[A]ll valid HTML entities in any context are recognized as such
-			// and converted into unicode characters before they are stored in
-			// the AST."
-			semicolon := bytes.IndexByte(p.data[p.pos+1:], ';')
-			// "Although HTML5 does accept some entities without a trailing
-			// semicolon (such as &copy), these are not recognized as entities
-			// here, because it makes the grammar too ambiguous."
-			if semicolon < 0 {
+		case '*', '_':
+			if p.options.disabled(Emphasis) {
 				p.pos++
 				break
 			}
-			semicolon += p.pos + 1
-			entity := string(p.data[p.pos+1 : semicolon])
-			var codepoints string
-
-			if len(entity) > 0 {
-				if entity[0] == '#' {
-					if len(entity) > 1 {
-						if entity[1] == 'x' || entity[1] == 'X' {
-							// "Hexadecimal entities consist of &# + either X or x + a
-							// string of 1-8 hexadecimal digits + ;."
-							if codepoint, err := strconv.ParseUint(entity[2:], 16, 32); err == nil {
-								codepoints = fmt.Sprintf("%c", codepoint)
-							}
-						} else {
-							// "Decimal entities consist of &# + a string of 1–8 arabic
-							// digits + ;. Again, these entities need to be recognised and
-							// tranformed into their corresponding UTF8 codepoints. Invalid
-							// Unicode codepoints will be written as the “unknown
-							// codepoint” character (0xFFFD)."
-							if codepoint, err := strconv.ParseUint(entity[1:], 10, 32); err == nil {
-								codepoints = fmt.Sprintf("%c", codepoint)
-							}
-						}
-					}
-				} else {
-					// "Named entities consist of & + any of the valid HTML5 entity names + ;."
-					codepoints = htmlEntities[entity]
+			p.finalizeString()
+			p.parseDelimiterRun()
+			p.resetString()
+		case '~':
+			numTildes := 0
+			for p.pos+numTildes < len(p.data) && p.data[p.pos+numTildes] == '~' {
+				numTildes++
+			}
+			// Only runs of exactly two tildes can delimit strikethrough.
+			if !p.options.Strikethrough || numTildes != 2 {
+				p.pos += numTildes
+				break
+			}
+			p.finalizeString()
+			p.parseDelimiterRun()
+			p.resetString()
+		case '"', '\'':
+			if !p.options.SmartPunctuation {
+				p.pos++
+				break
+			}
+			p.finalizeString()
+			p.parseDelimiterRun()
+			p.resetString()
+		case '-':
+			numHyphens := 0
+			for p.pos+numHyphens < len(p.data) && p.data[p.pos+numHyphens] == '-' {
+				numHyphens++
+			}
+			if !p.options.SmartPunctuation || numHyphens < 2 {
+				p.pos += numHyphens
+				break
+			}
+			p.finalizeString()
+			inline = &stringInline{content: smartDashes(numHyphens)}
+			p.pos += numHyphens
+			p.resetString()
+		case '.':
+			if !p.options.SmartPunctuation || !bytes.HasPrefix(p.data[p.pos:], []byte("...")) {
+				p.pos++
+				break
+			}
+			p.finalizeString()
+			inline = &stringInline{content: []byte("\u2026")}
+			p.pos += 3
+			p.resetString()
+		case '<':
+			if autolink, length := parseAutolink(p.data[p.pos:]); length > 0 && !p.options.disabled(Autolinks) {
+				p.finalizeString()
+				autolink.children[0].base().start = p.pos + 1
+				autolink.children[0].base().end = p.pos + length - 1
+				inline = autolink
+				p.pos += length
+				p.resetString()
+			} else if length := parseRawHTML(p.data[p.pos:]); length > 0 && !p.options.disabled(RawHTML) {
+				p.finalizeString()
+				inline = &rawHTML{content: p.data[p.pos : p.pos+length]}
+				p.pos += length
+				p.resetString()
+			} else {
+				p.pos++
+			}
+		case '[':
+			if p.options.disabled(Links) {
+				p.pos++
+				break
+			}
+			if p.options.Footnotes {
+				if ref, length := parseFootnoteReference(p.data[p.pos:], p.footnotes); length > 0 {
+					p.finalizeString()
+					inline = ref
+					p.pos += length
+					p.resetString()
+					break
 				}
 			}
-
-			if len(codepoints) == 0 {
+			p.finalizeString()
+			p.pushBracket(false)
+			p.pos++
+			p.resetString()
+		case '!':
+			if p.pos+1 >= len(p.data) || p.data[p.pos+1] != '[' {
+				p.pos++
+				break
+			}
+			if p.options.disabled(Images) {
+				// The bracket cannot open a link either.
+				p.pos += 2
+				break
+			}
+			p.finalizeString()
+			p.pushBracket(true)
+			p.pos += 2
+			p.resetString()
+		case ']':
+			p.finalizeString()
+			p.parseCloseBracket()
+			p.resetString()
+		case '&':
+			// "[A]ll valid HTML entities in any context are recognized as such
+			// and converted into unicode characters before they are stored in
+			// the AST."
+			codepoints, length := parseEntity(p.data[p.pos:])
+			if length == 0 {
 				p.pos++
 				break
 			}
 
 			p.finalizeString()
-			inline = &stringInline{[]byte(codepoints)}
-			p.pos = semicolon + 1
+			inline = &stringInline{content: []byte(codepoints)}
+			p.pos += length
 			p.resetString()
 		default:
+			// Like in the reference implementation, extended autolinks are
+			// not recognized in potential link text.
+			if p.options.AutolinkURLs && p.lastBracket == nil && p.atAutolinkBoundary() {
+				if autolink, length := parseExtendedAutolink(p.data[p.pos:]); length > 0 {
+					p.finalizeString()
+					autolink.children[0].base().start = p.pos
+					autolink.children[0].base().end = p.pos + length
+					inline = autolink
+					p.pos += length
+					p.resetString()
+					break
+				}
+			}
 			p.pos++
 		}
 
 		if inline != nil {
-			p.root.children = append(p.root.children, inline)
+			if s := inline.base(); s.end == 0 {
+				s.start, s.end = start, p.pos
+			}
+			p.inlines.PushBack(inline)
 		}
 	}
 	p.finalizeString()
 }
 
+// atAutolinkBoundary returns whether an extended autolink may start at the
+// current position.
+//
+// "All such recognized autolinks can only come at the beginning of a line,
+// after whitespace, or any of the delimiting characters *, _, ~, and (."
+func (p *inlineParser) atAutolinkBoundary() bool {
+	return p.pos == 0 || bytes.IndexByte([]byte(" \n*_~("), p.data[p.pos-1]) >= 0
+}
+
+// pushBracket adds the bracket at the current position as a string inline,
+// and pushes it onto the bracket stack, unless the stack is full already.
+func (p *inlineParser) pushBracket(image bool) {
+	length := 1
+	if image {
+		length = 2
+	}
+	element := p.inlines.PushBack(&stringInline{span{p.pos, p.pos + length}, p.data[p.pos : p.pos+length]})
+	depth := 1
+	if p.lastBracket != nil {
+		p.lastBracket.bracketAfter = true
+		depth = p.lastBracket.depth + 1
+	}
+	if depth > p.options.maxNestingDepth() {
+		// The bracket cannot open a link, so it is just text.
+		return
+	}
+	p.lastBracket = &bracket{
+		element:       element,
+		pos:           p.pos + length - 1,
+		image:         image,
+		active:        true,
+		prevDelimiter: p.lastDelimiter,
+		depth:         depth,
+		prev:          p.lastBracket,
+	}
+}
+
+// parseCloseBracket handles a ] character, which might close a link.
+func (p *inlineParser) parseCloseBracket() {
+	opener := p.lastBracket
+	closePos := p.pos
+	p.pos++
+	if opener == nil {
+		p.inlines.PushBack(&stringInline{span{closePos, p.pos}, p.data[closePos:p.pos]})
+		return
+	}
+	if !opener.active {
+		p.lastBracket = opener.prev
+		p.inlines.PushBack(&stringInline{span{closePos, p.pos}, p.data[closePos:p.pos]})
+		return
+	}
+
+	// "An inline link consists of a link text followed immediately by a left
+	// parenthesis (, optional whitespace, an optional link destination, an
+	// optional link title separated from the link destination by whitespace,
+	// optional whitespace, and a right parenthesis )."
+	destination, title, length := parseInlineLinkTail(p.data[p.pos:])
+	if length > 0 {
+		p.pos += length
+	} else if ref := p.parseLinkReference(opener, closePos); ref != nil {
+		destination, title = ref.destination, ref.title
+	} else {
+		p.lastBracket = opener.prev
+		p.inlines.PushBack(&stringInline{span{closePos, closePos + 1}, p.data[closePos : closePos+1]})
+		return
+	}
+
+	// The link text is everything after the opening bracket. Emphasis
+	// delimiters inside it are matched now, so they cannot match anything
+	// outside.
+	p.processEmphasis(opener.prevDelimiter)
+	var children []Inline
+	for e := opener.element.Next(); e != nil; {
+		next := e.Next()
+		children = append(children, p.inlines.Remove(e).(Inline))
+		e = next
+	}
+	p.inlines.Remove(opener.element)
+	p.lastBracket = opener.prev
+
+	linkSpan := span{opener.pos, p.pos}
+	if opener.image {
+		// The image starts at the ! before the opening bracket.
+		linkSpan.start--
+		p.inlines.PushBack(&image{
+			span:        linkSpan,
+			children:    children,
+			destination: destination,
+			title:       title,
+		})
+		return
+	}
+
+	p.inlines.PushBack(&link{
+		span:        linkSpan,
+		children:    children,
+		destination: destination,
+		title:       title,
+	})
+	// "Links may not contain other links, at any level of nesting."
+	for b := p.lastBracket; b != nil; b = b.prev {
+		if !b.image {
+			b.active = false
+		}
+	}
+}
+
+// parseLinkReference looks for a reference link, given the opening bracket and
+// the position of the closing bracket of the link text. It consumes the label,
+// if any, and returns the matching definition, or nil if there is none.
+//
+// "There are three kinds of reference links: full, collapsed, and shortcut."
+func (p *inlineParser) parseLinkReference(opener *bracket, closePos int) *linkReference {
+	label, length := parseLinkLabel(p.data[p.pos:])
+	if length == 0 {
+		// "A collapsed reference link consists of a link label that matches a
+		// link reference definition elsewhere in the document, followed by
+		// the string []." "A shortcut reference link consists of a link label
+		// that matches a link reference definition elsewhere in the document
+		// and is not followed by [] or a link label."
+		if opener.bracketAfter {
+			return nil
+		}
+		label = p.data[opener.pos+1 : closePos]
+		if bytes.HasPrefix(p.data[p.pos:], []byte("[]")) {
+			length = 2
+		}
+	}
+	ref := p.references[normalizeLabel(label)]
+	if ref != nil {
+		p.pos += length
+	}
+	return ref
+}
+
+// parseDelimiterRun consumes a run of * or _ characters, adds it as a string
+// inline and pushes it onto the delimiter stack.
+//
+// "A delimiter run is either a sequence of one or more * characters that is
+// not preceded or followed by a non-backslash-escaped * character, or a
+// sequence of one or more _ characters that is not preceded or followed by a
+// non-backslash-escaped _ character."
+//
+// With smart punctuation, a single ' or " character is also treated as a
+// delimiter run, so that quotes are matched up in the same way as emphasis.
+// Until it is matched, a ' is rendered as a right single quote (which is also
+// the apostrophe) and a " as a left double quote.
+func (p *inlineParser) parseDelimiterRun() {
+	char := p.data[p.pos]
+	start := p.pos
+	if isQuote(char) {
+		p.pos++
+	}
+	for p.pos < len(p.data) && p.data[p.pos] == char && !isQuote(char) {
+		p.pos++
+	}
+
+	// "The beginning and the end of the line count as Unicode whitespace."
+	before, after := '\n', '\n'
+	if start > 0 {
+		before, _ = utf8.DecodeLastRune(p.data[:start])
+	}
+	if p.pos < len(p.data) {
+		after, _ = utf8.DecodeRune(p.data[p.pos:])
+	}
+
+	// "A left-flanking delimiter run is a delimiter run that is (1) not
+	// followed by Unicode whitespace, and either (2a) not followed by a
+	// Unicode punctuation character, or (2b) followed by a Unicode
+	// punctuation character and preceded by Unicode whitespace or a Unicode
+	// punctuation character."
+	leftFlanking := !isUnicodeWhitespace(after) &&
+		(!isPunct(after) || isUnicodeWhitespace(before) || isPunct(before))
+	// "A right-flanking delimiter run is a delimiter run that is (1) not
+	// preceded by Unicode whitespace, and either (2a) not preceded by a
+	// Unicode punctuation character, or (2b) preceded by a Unicode
+	// punctuation character and followed by Unicode whitespace or a Unicode
+	// punctuation character."
+	rightFlanking := !isUnicodeWhitespace(before) &&
+		(!isPunct(before) || isUnicodeWhitespace(after) || isPunct(after))
+
+	content := p.data[start:p.pos]
+	var canOpen, canClose bool
+	if isQuote(char) {
+		// Like the reference implementation, a quote directly after a link
+		// or parenthesis is taken to be an apostrophe or closing quote.
+		canOpen = leftFlanking && !rightFlanking && before != ']' && before != ')'
+		canClose = rightFlanking
+		if char == '\'' {
+			content = []byte("\u2019")
+		} else {
+			content = []byte("\u201c")
+		}
+	} else if char == '*' || char == '~' {
+		// "A single * character can open emphasis iff it is part of a
+		// left-flanking delimiter run."
+		canOpen = leftFlanking
+		canClose = rightFlanking
+	} else {
+		// "A single _ character can open emphasis iff it is part of a
+		// left-flanking delimiter run and either (a) not part of a
+		// right-flanking delimiter run or (b) part of a right-flanking
+		// delimiter run preceded by a Unicode punctuation character."
+		canOpen = leftFlanking && (!rightFlanking || isPunct(before))
+		canClose = rightFlanking && (!leftFlanking || isPunct(after))
+	}
+
+	d := &delimiter{
+		element:   p.inlines.PushBack(&stringInline{span{start, p.pos}, content}),
+		char:      char,
+		count:     p.pos - start,
+		origCount: p.pos - start,
+		canOpen:   canOpen,
+		canClose:  canClose,
+		prev:      p.lastDelimiter,
+	}
+	if p.lastDelimiter != nil {
+		p.lastDelimiter.next = d
+	}
+	p.lastDelimiter = d
+}
+
+// removeDelimiter removes the given delimiter from the delimiter stack. The
+// corresponding inline is left in place.
+func (p *inlineParser) removeDelimiter(d *delimiter) {
+	if d.prev != nil {
+		d.prev.next = d.next
+	}
+	if d.next != nil {
+		d.next.prev = d.prev
+	} else {
+		p.lastDelimiter = d.prev
+	}
+}
+
+type openersBottomKey struct {
+	char     byte
+	canOpen  bool
+	countMod int
+}
+
+// processEmphasis matches up the emphasis delimiters above stackBottom (which
+// may be nil to process the entire stack), and removes them from the stack.
+//
+// See http://spec.commonmark.org/0.29/#phase-2-inline-structure
+func (p *inlineParser) processEmphasis(stackBottom *delimiter) {
+	// openersBottom keeps track of the lower bound when searching for an
+	// opener for a particular kind of closer, so we don't search the same
+	// part of the stack over and over.
+	openersBottom := make(map[openersBottomKey]*delimiter)
+
+	var closer *delimiter
+	for d := p.lastDelimiter; d != nil && d != stackBottom; d = d.prev {
+		closer = d
+	}
+
+	for closer != nil {
+		if !closer.canClose {
+			closer = closer.next
+			continue
+		}
+
+		// Look back in the stack for the first matching opener.
+		key := openersBottomKey{closer.char, closer.canOpen, closer.origCount % 3}
+		bottom, ok := openersBottom[key]
+		if !ok {
+			bottom = stackBottom
+		}
+		var opener *delimiter
+		for d := closer.prev; d != nil && d != stackBottom && d != bottom; d = d.prev {
+			if d.char != closer.char || !d.canOpen {
+				continue
+			}
+			// "If one of the delimiters can both open and close emphasis, then
+			// the sum of the lengths of the delimiter runs containing the
+			// opening and closing delimiters must not be a multiple of 3
+			// unless both lengths are multiples of 3."
+			if (d.canClose || closer.canOpen) &&
+				(d.origCount+closer.origCount)%3 == 0 &&
+				(d.origCount%3 != 0 || closer.origCount%3 != 0) {
+				continue
+			}
+			opener = d
+			break
+		}
+
+		if isQuote(closer.char) {
+			// A closing quote is rendered as such, even if there is no
+			// matching opening quote.
+			closerText := closer.element.Value.(*stringInline)
+			if closer.char == '\'' {
+				closerText.content = []byte("\u2019")
+			} else {
+				closerText.content = []byte("\u201d")
+			}
+			next := closer.next
+			if opener != nil {
+				openerText := opener.element.Value.(*stringInline)
+				if opener.char == '\'' {
+					openerText.content = []byte("\u2018")
+				} else {
+					openerText.content = []byte("\u201c")
+				}
+				p.removeDelimiter(opener)
+				p.removeDelimiter(closer)
+			} else {
+				openersBottom[key] = closer.prev
+				if !closer.canOpen {
+					p.removeDelimiter(closer)
+				}
+			}
+			closer = next
+			continue
+		}
+
+		if opener == nil {
+			openersBottom[key] = closer.prev
+			next := closer.next
+			if !closer.canOpen {
+				p.removeDelimiter(closer)
+			}
+			closer = next
+			continue
+		}
+
+		// "Figure out whether we have emphasis or strong emphasis: if both
+		// closer and opener spans have length >= 2, we have strong, otherwise
+		// regular."
+		n := 1
+		if opener.count >= 2 && closer.count >= 2 {
+			n = 2
+		}
+		opener.count -= n
+		closer.count -= n
+		openerText := opener.element.Value.(*stringInline)
+		openerText.content = openerText.content[:len(openerText.content)-n]
+		openerText.end -= n
+		closerText := closer.element.Value.(*stringInline)
+		closerText.content = closerText.content[n:]
+		closerText.start += n
+
+		// "Insert an emph or strong emph node accordingly, after the text node
+		// corresponding to the opener."
+		var children []Inline
+		for e := opener.element.Next(); e != closer.element; {
+			next := e.Next()
+			children = append(children, p.inlines.Remove(e).(Inline))
+			e = next
+		}
+		// The emphasis includes the delimiters that were just removed.
+		emphSpan := span{openerText.end, closerText.start}
+		var emph Inline
+		if closer.char == '~' {
+			emph = &strikethrough{emphSpan, children}
+		} else if n == 2 {
+			emph = &strongEmphasis{emphSpan, children}
+		} else {
+			emph = &emphasis{emphSpan, children}
+		}
+		p.inlines.InsertAfter(emph, opener.element)
+
+		// "Remove any delimiters between the opener and closer from the
+		// delimiter stack."
+		opener.next = closer
+		closer.prev = opener
+
+		// "If either the opening or closing text node becomes empty, remove
+		// it and remove the corresponding element of the delimiter stack."
+		if opener.count == 0 {
+			p.inlines.Remove(opener.element)
+			p.removeDelimiter(opener)
+		}
+		if closer.count == 0 {
+			next := closer.next
+			p.inlines.Remove(closer.element)
+			p.removeDelimiter(closer)
+			closer = next
+		}
+	}
+
+	// "After we're done, we remove all delimiters above stack_bottom from the
+	// delimiter stack."
+	for p.lastDelimiter != nil && p.lastDelimiter != stackBottom {
+		p.removeDelimiter(p.lastDelimiter)
+	}
+}
+
+// listToSlice returns the values of the list as a slice of Inlines.
+func listToSlice(l *list.List) []Inline {
+	inlines := make([]Inline, 0, l.Len())
+	for e := l.Front(); e != nil; e = e.Next() {
+		inlines = append(inlines, e.Value.(Inline))
+	}
+	return inlines
+}
+
+// parseEntity recognizes an entity or numeric character reference at the
+// start of data, which must start with '&'. It returns the UTF-8 encoded
+// codepoints that the reference represents, and the length of the reference
+// in bytes. If data does not start with a valid reference, the length is 0.
+func parseEntity(data []byte) (string, int) {
+	semicolon := bytes.IndexByte(data, ';')
+	// "Although HTML5 does accept some entities without a trailing semicolon
+	// (such as &copy), these are not recognized as entities here, because it
+	// makes the grammar too ambiguous."
+	if semicolon < 0 {
+		return "", 0
+	}
+	entity := string(data[1:semicolon])
+	var codepoints string
+
+	switch {
+	case strings.HasPrefix(entity, "#x"), strings.HasPrefix(entity, "#X"):
+		// "Hexadecimal numeric character references consist of &# + either X
+		// or x + a string of 1-6 hexadecimal digits + ;."
+		if digits := entity[2:]; len(digits) >= 1 && len(digits) <= 6 {
+			if codepoint, err := strconv.ParseUint(digits, 16, 32); err == nil {
+				codepoints = codepointToString(codepoint)
+			}
+		}
+	case strings.HasPrefix(entity, "#"):
+		// "Decimal numeric character references consist of &# + a string of
+		// 1--7 arabic digits + ;."
+		if digits := entity[1:]; len(digits) >= 1 && len(digits) <= 7 {
+			if codepoint, err := strconv.ParseUint(digits, 10, 32); err == nil {
+				codepoints = codepointToString(codepoint)
+			}
+		}
+	case len(entity) > 0:
+		// "Named entities consist of & + any of the valid HTML5 entity names + ;."
+		codepoints = htmlEntities[entity]
+	}
+
+	if len(codepoints) == 0 {
+		return "", 0
+	}
+	return codepoints, semicolon + 1
+}
+
+// codepointToString returns the UTF-8 encoding of the given codepoint.
+//
+// "If a character reference represents a code point that is invalid or not
+// allowed, it is replaced by the REPLACEMENT CHARACTER (U+FFFD). For security
+// reasons, the code point U+0000 will also be replaced by U+FFFD."
+func codepointToString(codepoint uint64) string {
+	if codepoint == 0 || codepoint > unicode.MaxRune {
+		return string(unicode.ReplacementChar)
+	}
+	// Surrogate halves are converted to U+FFFD by the conversion itself.
+	return string(rune(codepoint))
+}
+
 var asciiPunct = []byte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~")
 
+// isQuote returns whether the character is a straight quote, which is turned
+// into a curly quote with smart punctuation.
+func isQuote(char byte) bool {
+	return char == '\'' || char == '"'
+}
+
+// smartDashes returns the en and em dashes that replace a run of two or more
+// hyphens with smart punctuation. Runs of a multiple of three hyphens become
+// em dashes, and runs of a multiple of two become en dashes. Other runs use
+// as many em dashes as possible, followed by one or two en dashes.
+func smartDashes(numHyphens int) []byte {
+	var numEm, numEn int
+	switch {
+	case numHyphens%3 == 0:
+		numEm = numHyphens / 3
+	case numHyphens%2 == 0:
+		numEn = numHyphens / 2
+	case numHyphens%3 == 2:
+		numEm, numEn = (numHyphens-2)/3, 1
+	default:
+		numEm, numEn = (numHyphens-4)/3, 2
+	}
+	return []byte(strings.Repeat("\u2014", numEm) + strings.Repeat("\u2013", numEn))
+}
+
 func isASCIIPunct(char byte) bool {
 	return bytes.IndexByte(asciiPunct, char) >= 0
 }
 
+// isPunct returns whether r is a punctuation character, which is either an
+// ASCII punctuation character or anything in the Unicode P categories.
+func isPunct(r rune) bool {
+	return r < utf8.RuneSelf && isASCIIPunct(byte(r)) || unicode.IsPunct(r)
+}
+
+// isUnicodeWhitespace returns whether r is "a character in the Unicode Zs
+// general category, or a tab (U+0009), line feed (U+000A), form feed
+// (U+000C), or carriage return (U+000D)". This is narrower than
+// unicode.IsSpace, which also includes U+000B, U+0085, U+2028 and U+2029.
+func isUnicodeWhitespace(r rune) bool {
+	switch r {
+	case '\t', '\n', '\f', '\r':
+		return true
+	}
+	return unicode.Is(unicode.Zs, r)
+}
+
 func backtickStringIndex(data []byte, start, length int) int {
 	var count int
 	for i := start; i < len(data); i++ {
@@ -207,21 +930,19 @@ func backtickStringIndex(data []byte, start, length int) int {
 	return -1
 }
 
-func collapseSpace(data []byte) []byte {
-	var out []byte
-	var prevWasSpace bool
-	for _, c := range data {
-		if c == ' ' || c == '\n' {
-			if !prevWasSpace {
-				out = append(out, ' ')
-				prevWasSpace = true
-			}
-		} else {
-			out = append(out, c)
-			prevWasSpace = false
-		}
+// codeSpanContent returns the content of a code span, given the raw bytes
+// between the opening and closing backtick strings.
+func codeSpanContent(data []byte) []byte {
+	// "First, line endings are converted to spaces."
+	content := bytes.Replace(data, []byte{'\n'}, []byte{' '}, -1)
+	// "If the resulting string both begins and ends with a space character,
+	// but does not consist entirely of space characters, a single space
+	// character is removed from the front and back."
+	if len(content) >= 2 && content[0] == ' ' && content[len(content)-1] == ' ' &&
+		len(bytes.Trim(content, " ")) > 0 {
+		content = content[1 : len(content)-1]
 	}
-	return out
+	return content
 }
 
 func (p *inlineParser) resetString() {
@@ -233,5 +954,5 @@ func (p *inlineParser) finalizeString() {
 		return
 	}
 	str := p.data[p.stringStart:p.pos]
-	p.root.children = append(p.root.children, &stringInline{str})
+	p.inlines.PushBack(&stringInline{span{p.stringStart, p.pos}, str})
 }
@@ -0,0 +1,354 @@
+package commonmark
+
+import (
+	"testing"
+)
+
+func TestCodeSpans(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"`foo`\n", "<p><code>foo</code></p>\n"},
+		{"`` foo ` bar ``\n", "<p><code>foo ` bar</code></p>\n"},
+		{"``` `` ```\n", "<p><code>``</code></p>\n"},
+		{"` `` `\n", "<p><code>``</code></p>\n"},
+		{"`  ``  `\n", "<p><code> `` </code></p>\n"},
+		{"` a`\n", "<p><code> a</code></p>\n"},
+		{"`  `\n", "<p><code>  </code></p>\n"},
+		{"``\nfoo\nbar  \nbaz\n``\n", "<p><code>foo bar   baz</code></p>\n"},
+		{"`foo   bar \nbaz`\n", "<p><code>foo   bar  baz</code></p>\n"},
+		{"`<a>&amp;`\n", "<p><code>&lt;a&gt;&amp;amp;</code></p>\n"},
+		{"`foo\\`bar`\n", "<p><code>foo\\</code>bar`</p>\n"},
+		{"```foo``\n", "<p>```foo``</p>\n"},
+		{"`foo\n", "<p>`foo</p>\n"},
+		{"`foo``bar``\n", "<p>`foo<code>bar</code></p>\n"},
+	})
+}
+
+func TestEmphasis(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"*foo bar*\n", "<p><em>foo bar</em></p>\n"},
+		{"_foo bar_\n", "<p><em>foo bar</em></p>\n"},
+		{"**foo bar**\n", "<p><strong>foo bar</strong></p>\n"},
+		{"__foo bar__\n", "<p><strong>foo bar</strong></p>\n"},
+		{"a * foo bar*\n", "<p>a * foo bar*</p>\n"},
+		{"foo*bar*\n", "<p>foo<em>bar</em></p>\n"},
+		{"foo_bar_\n", "<p>foo_bar_</p>\n"},
+		{"_foo_bar_\n", "<p><em>foo_bar</em></p>\n"},
+		{"_foo_bar_baz_\n", "<p><em>foo_bar_baz</em></p>\n"},
+		{"***strong emph***\n", "<p><em><strong>strong emph</strong></em></p>\n"},
+		{"***strong** in emph*\n", "<p><em><strong>strong</strong> in emph</em></p>\n"},
+		{"***emph* in strong**\n", "<p><strong><em>emph</em> in strong</strong></p>\n"},
+		{"*foo **bar** baz*\n", "<p><em>foo <strong>bar</strong> baz</em></p>\n"},
+		{"*foo**bar**baz*\n", "<p><em>foo<strong>bar</strong>baz</em></p>\n"},
+		{"*foo *bar**\n", "<p><em>foo <em>bar</em></em></p>\n"},
+		{"**foo*\n", "<p>*<em>foo</em></p>\n"},
+		{"*foo**\n", "<p><em>foo</em>*</p>\n"},
+		{"*(*foo*)*\n", "<p><em>(<em>foo</em>)</em></p>\n"},
+		{"** is not an empty emphasis\n", "<p>** is not an empty emphasis</p>\n"},
+		{"*< b>*\n", "<p><em>&lt; b&gt;</em></p>\n"},
+		{"\\*not emphasized*\n", "<p>*not emphasized*</p>\n"},
+		{"*a `*`*\n", "<p><em>a <code>*</code></em></p>\n"},
+		// Intraword * emphasis is allowed, but intraword _ is not, unless the
+		// delimiter run is bounded by punctuation on the inner side and by
+		// whitespace or punctuation on the outer side.
+		{"5*6*78\n", "<p>5<em>6</em>78</p>\n"},
+		{"foo*bar*baz\n", "<p>foo<em>bar</em>baz</p>\n"},
+		{"foo_bar_baz\n", "<p>foo_bar_baz</p>\n"},
+		{"пристаням_стремятся_\n", "<p>пристаням_стремятся_</p>\n"},
+		{"foo_(bar)_\n", "<p>foo_(bar)_</p>\n"},
+		{"foo-_(bar)_\n", "<p>foo-<em>(bar)</em></p>\n"},
+		{"_(bar)_.\n", "<p><em>(bar)</em>.</p>\n"},
+		{"(_foo_)\n", "<p>(<em>foo</em>)</p>\n"},
+		{"_(_foo_)_\n", "<p><em>(<em>foo</em>)</em></p>\n"},
+	})
+}
+
+func TestEmphasisRuleOfThree(t *testing.T) {
+	// "If one of the delimiters can both open and close emphasis, then the sum
+	// of the lengths of the delimiter runs containing the opening and closing
+	// delimiters must not be a multiple of 3 unless both lengths are
+	// multiples of 3."
+	runConversionTests(t, []conversionTest{
+		{"*foo**bar*\n", "<p><em>foo**bar</em></p>\n"},
+		{"*foo**bar**baz*\n", "<p><em>foo<strong>bar</strong>baz</em></p>\n"},
+		{"**foo*bar*baz**\n", "<p><strong>foo<em>bar</em>baz</strong></p>\n"},
+		{"***foo***\n", "<p><em><strong>foo</strong></em></p>\n"},
+		{"foo***bar***baz\n", "<p>foo<em><strong>bar</strong></em>baz</p>\n"},
+		{"foo******bar*********baz\n", "<p>foo<strong><strong><strong>bar</strong></strong></strong>***baz</p>\n"},
+		{"*foo**bar***\n", "<p><em>foo<strong>bar</strong></em></p>\n"},
+		{"***foo** bar*\n", "<p><em><strong>foo</strong> bar</em></p>\n"},
+		{"*foo **bar***\n", "<p><em>foo <strong>bar</strong></em></p>\n"},
+		{"_foo__bar_\n", "<p><em>foo__bar</em></p>\n"},
+		// The rule does not apply if neither delimiter can both open and
+		// close.
+		{"*foo **bar** baz*\n", "<p><em>foo <strong>bar</strong> baz</em></p>\n"},
+		{"**foo*\n", "<p>*<em>foo</em></p>\n"},
+	})
+}
+
+func TestEmphasisUnicodeFlanking(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		// CJK characters are neither whitespace nor punctuation.
+		{"日本*語*です\n", "<p>日本<em>語</em>です</p>\n"},
+		{"日本**語**です\n", "<p>日本<strong>語</strong>です</p>\n"},
+		{"日本_語_です\n", "<p>日本_語_です</p>\n"},
+		{"*日本語*。\n", "<p><em>日本語</em>。</p>\n"},
+		{"*「日本語」*です\n", "<p>*「日本語」*です</p>\n"},
+		{"これは*「日本語」*です\n", "<p>これは*「日本語」*です</p>\n"},
+		{"これは *「日本語」* です\n", "<p>これは <em>「日本語」</em> です</p>\n"},
+		// Non-ASCII punctuation.
+		{"«*foo*»\n", "<p>«<em>foo</em>»</p>\n"},
+		{"«_foo_»\n", "<p>«<em>foo</em>»</p>\n"},
+		{"*«foo»*\n", "<p><em>«foo»</em></p>\n"},
+		{"a*«foo»*b\n", "<p>a*«foo»*b</p>\n"},
+		{"_é_ and é_é_\n", "<p><em>é</em> and é_é_</p>\n"},
+		// Non-ASCII whitespace.
+		{"*\u00a0foo*\n", "<p>*\u00a0foo*</p>\n"},
+		{"*foo\u3000*\n", "<p>*foo\u3000*</p>\n"},
+		// U+2028 is not in category Zs, so it is not whitespace here.
+		{"*foo\u2028*\n", "<p><em>foo\u2028</em></p>\n"},
+	})
+}
+
+func TestInlineLinks(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"[link](/uri \"title\")\n", "<p><a href=\"/uri\" title=\"title\">link</a></p>\n"},
+		{"[link](/uri 'title')\n", "<p><a href=\"/uri\" title=\"title\">link</a></p>\n"},
+		{"[link](/uri (title))\n", "<p><a href=\"/uri\" title=\"title\">link</a></p>\n"},
+		{"[link](/uri)\n", "<p><a href=\"/uri\">link</a></p>\n"},
+		{"[link]()\n", "<p><a href=\"\">link</a></p>\n"},
+		{"[link](<>)\n", "<p><a href=\"\">link</a></p>\n"},
+		{"[link](<my uri>)\n", "<p><a href=\"my%20uri\">link</a></p>\n"},
+		{"[link](my uri)\n", "<p>[link](my uri)</p>\n"},
+		{"[link](foo(and(bar)))\n", "<p><a href=\"foo(and(bar))\">link</a></p>\n"},
+		{"[link](foo(and(bar))\n", "<p>[link](foo(and(bar))</p>\n"},
+		{"[link](foo\\(and\\(bar\\))\n", "<p><a href=\"foo(and(bar)\">link</a></p>\n"},
+		{"[link](/url \"title \\\"quoted\\\"\")\n", "<p><a href=\"/url\" title=\"title &quot;quoted&quot;\">link</a></p>\n"},
+		{"[link](   /uri\n  \"title\"  )\n", "<p><a href=\"/uri\" title=\"title\">link</a></p>\n"},
+		{"[link](/uri\"title\")\n", "<p><a href=\"/uri%22title%22\">link</a></p>\n"},
+		{"[link *foo **bar** `#`*](/uri)\n", "<p><a href=\"/uri\">link <em>foo <strong>bar</strong> <code>#</code></em></a></p>\n"},
+		{"[foo [bar](/uri)](/uri)\n", "<p>[foo <a href=\"/uri\">bar</a>](/uri)</p>\n"},
+		{"*[foo*](/uri)\n", "<p>*<a href=\"/uri\">foo*</a></p>\n"},
+		{"[foo`](/uri)`\n", "<p>[foo<code>](/uri)</code></p>\n"},
+		{"[link](foo%20bä)\n", "<p><a href=\"foo%20b%C3%A4\">link</a></p>\n"},
+		{"]not a link[\n", "<p>]not a link[</p>\n"},
+		// Brackets in link text.
+		{"[a [b] c](/url)\n", "<p><a href=\"/url\">a [b] c</a></p>\n"},
+		{"[link [foo [bar]]](/uri)\n", "<p><a href=\"/uri\">link [foo [bar]]</a></p>\n"},
+		{"[a [b c](/url)\n", "<p>[a <a href=\"/url\">b c</a></p>\n"},
+		{"[a] b] c](/url)\n", "<p>[a] b] c](/url)</p>\n"},
+		{"[link \\[bar](/uri)\n", "<p><a href=\"/uri\">link [bar</a></p>\n"},
+		{"[![alt](img)](url)\n", "<p><a href=\"url\"><img src=\"img\" alt=\"alt\" /></a></p>\n"},
+		{"[a ![b [c]](d)](e)\n", "<p><a href=\"e\">a <img src=\"d\" alt=\"b [c]\" /></a></p>\n"},
+		// Empty titles are left out, like missing ones.
+		{"[a](/u \"\")\n", "<p><a href=\"/u\">a</a></p>\n"},
+		{"[a](/u '') ![b](/v ())\n", "<p><a href=\"/u\">a</a> <img src=\"/v\" alt=\"b\" /></p>\n"},
+		{"[a]\n\n[a]: /u \"\"\n", "<p><a href=\"/u\">a</a></p>\n"},
+	})
+}
+
+func TestReferenceLinks(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"[foo][bar]\n\n[bar]: /url \"title\"\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+		{"[foo][]\n\n[foo]: /url\n", "<p><a href=\"/url\">foo</a></p>\n"},
+		{"[foo]\n\n[foo]: /url\n", "<p><a href=\"/url\">foo</a></p>\n"},
+		{"[*foo* bar]\n\n[*foo* bar]: /url\n", "<p><a href=\"/url\"><em>foo</em> bar</a></p>\n"},
+		{"[foo][BaR]\n\n[bar]: /url\n", "<p><a href=\"/url\">foo</a></p>\n"},
+		{"[Толпой][Толпой] is a Russian word.\n\n[ТОЛПОЙ]: /url\n", "<p><a href=\"/url\">Толпой</a> is a Russian word.</p>\n"},
+		{"[Baz][Foo bar]\n\n[Foo\n  bar]: /url\n", "<p><a href=\"/url\">Baz</a></p>\n"},
+		{"[foo]: /url1\n\n[foo]: /url2\n\n[bar][foo]\n", "<p><a href=\"/url1\">bar</a></p>\n"},
+		{"[foo][bar]\n\n[foo]: /url\n", "<p>[foo][bar]</p>\n"},
+		{"[bar][foo\\!]\n\n[foo!]: /url\n", "<p>[bar][foo!]</p>\n"},
+		{"[foo]\n", "<p>[foo]</p>\n"},
+		// Whitespace in labels is trimmed and collapsed before matching.
+		{"[foo bar]\n\n[foo   bar]: /url\n", "<p><a href=\"/url\">foo bar</a></p>\n"},
+		{"[ foo\nbar  ][]\n\n[Foo Bar]: /url\n", "<p><a href=\"/url\"> foo\nbar  </a></p>\n"},
+		{"![a][ b\n c ]\n\n[b c]: /img\n", "<p><img src=\"/img\" alt=\"a\" /></p>\n"},
+		{"[foo\u00a0bar]\n\n[foo\u00a0bar]: /url\n", "<p><a href=\"/url\">foo\u00a0bar</a></p>\n"},
+		{"[foo bar]\n\n[foo\u00a0bar]: /url\n", "<p>[foo bar]</p>\n"},
+	})
+}
+
+func TestImages(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"![foo](/url \"title\")\n", "<p><img src=\"/url\" alt=\"foo\" title=\"title\" /></p>\n"},
+		{"![foo *bar*](/img.png)\n", "<p><img src=\"/img.png\" alt=\"foo bar\" /></p>\n"},
+		{"![foo ![bar](/b)](/a)\n", "<p><img src=\"/a\" alt=\"foo bar\" /></p>\n"},
+		{"![foo [bar](/b)](/a)\n", "<p><img src=\"/a\" alt=\"foo bar\" /></p>\n"},
+		{"![`<code>`](/a)\n", "<p><img src=\"/a\" alt=\"&lt;code&gt;\" /></p>\n"},
+		{"![](/url)\n", "<p><img src=\"/url\" alt=\"\" /></p>\n"},
+		{"[![moon](moon.jpg)](/uri)\n", "<p><a href=\"/uri\"><img src=\"moon.jpg\" alt=\"moon\" /></a></p>\n"},
+		{"![foo][bar]\n\n[bar]: /url\n", "<p><img src=\"/url\" alt=\"foo\" /></p>\n"},
+		{"![foo][]\n\n[foo]: /url \"title\"\n", "<p><img src=\"/url\" alt=\"foo\" title=\"title\" /></p>\n"},
+		{"![*foo* bar]\n\n[*foo* bar]: /url\n", "<p><img src=\"/url\" alt=\"foo bar\" /></p>\n"},
+		{"!\\[foo]\n\n[foo]: /url\n", "<p>![foo]</p>\n"},
+		{"\\![foo]\n\n[foo]: /url\n", "<p>!<a href=\"/url\">foo</a></p>\n"},
+		{"!foo\n", "<p>!foo</p>\n"},
+		// The alt text is the plain text of the inline content, while a title
+		// is literal text that is only escaped.
+		{"![*x*](y)\n", "<p><img src=\"y\" alt=\"x\" /></p>\n"},
+		{"![a **b** `c` [d](e)](y \"*t*\")\n", "<p><img src=\"y\" alt=\"a b c d\" title=\"*t*\" /></p>\n"},
+		{"![a<br>\\&amp;](y)\n", "<p><img src=\"y\" alt=\"a&lt;br&gt;&amp;amp;\" /></p>\n"},
+		{"![x](y \"*a* `b` <c> & \\\"d\\\" [e](f)\")\n",
+			"<p><img src=\"y\" alt=\"x\" title=\"*a* `b` &lt;c&gt; &amp; &quot;d&quot; [e](f)\" /></p>\n"},
+		{"[x](y '**a** &amp; \\*b\\*')\n", "<p><a href=\"y\" title=\"**a** &amp; *b*\">x</a></p>\n"},
+	})
+}
+
+func TestInlinePrecedence(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		// "Code span backticks have higher precedence than any other inline
+		// constructs except HTML tags and autolinks."
+		{"*foo`*`\n", "<p>*foo<code>*</code></p>\n"},
+		{"[not a `link](/foo`)\n", "<p>[not a <code>link](/foo</code>)</p>\n"},
+		{"`<a href=\"`\">`\n", "<p><code>&lt;a href=&quot;</code>&quot;&gt;`</p>\n"},
+		{"<a href=\"`\">`\n", "<p><a href=\"`\">`</p>\n"},
+		{"`<http://foo.bar.`baz>`\n", "<p><code>&lt;http://foo.bar.</code>baz&gt;`</p>\n"},
+		{"<http://foo.bar.`baz>`\n", "<p><a href=\"http://foo.bar.%60baz\">http://foo.bar.`baz</a>`</p>\n"},
+		// "Inline code spans, links, images, and HTML tags group more tightly
+		// than emphasis."
+		{"*[foo*](/url)\n", "<p>*<a href=\"/url\">foo*</a></p>\n"},
+		{"_foo [bar_](/url)\n", "<p>_foo <a href=\"/url\">bar_</a></p>\n"},
+		{"*<img src=\"foo\" title=\"*\"/>\n", "<p>*<img src=\"foo\" title=\"*\"/></p>\n"},
+		{"**<a href=\"**\">\n", "<p>**<a href=\"**\"></p>\n"},
+		{"__<a href=\"__\">\n", "<p>__<a href=\"__\"></p>\n"},
+		{"*a `*`*\n", "<p><em>a <code>*</code></em></p>\n"},
+		{"_a `_`_\n", "<p><em>a <code>_</code></em></p>\n"},
+		{"**a<http://foo.bar/?q=**>\n", "<p>**a<a href=\"http://foo.bar/?q=**\">http://foo.bar/?q=**</a></p>\n"},
+		{"__a<http://foo.bar/?q=__>\n", "<p>__a<a href=\"http://foo.bar/?q=__\">http://foo.bar/?q=__</a></p>\n"},
+		// Delimiters inside code spans, raw HTML and autolinks cannot pair
+		// with those outside, but emphasis can contain them whole.
+		{"*foo `bar* baz`\n", "<p>*foo <code>bar* baz</code></p>\n"},
+		{"**a `**` b\n", "<p>**a <code>**</code> b</p>\n"},
+		{"_a `_` b_\n", "<p><em>a <code>_</code> b</em></p>\n"},
+		{"*a <span title=\"*\">b*\n", "<p><em>a <span title=\"*\">b</em></p>\n"},
+		{"*a <!-- * --> b*\n", "<p><em>a <!-- * --> b</em></p>\n"},
+		{"*a <http://x/*>*\n", "<p><em>a <a href=\"http://x/*\">http://x/*</a></em></p>\n"},
+		// "The brackets in link text bind more tightly than markers for
+		// emphasis and strong emphasis", but code spans, autolinks and raw
+		// HTML bind more tightly than the brackets.
+		{"[foo *bar](baz*)\n", "<p><a href=\"baz*\">foo *bar</a></p>\n"},
+		{"[foo`](/uri)`\n", "<p>[foo<code>](/uri)</code></p>\n"},
+		{"[foo<http://example.com/?search=](uri)>\n", "<p>[foo<a href=\"http://example.com/?search=%5D(uri)\">http://example.com/?search=](uri)</a></p>\n"},
+		{"[foo <bar attr=\"](baz)\">\n", "<p>[foo <bar attr=\"](baz)\"></p>\n"},
+	})
+}
+
+func TestHardLineBreaks(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"foo  \nbar\n", "<p>foo<br />\nbar</p>\n"},
+		{"foo\\\nbar\n", "<p>foo<br />\nbar</p>\n"},
+		{"foo       \nbar\n", "<p>foo<br />\nbar</p>\n"},
+		{"foo  \n     bar\n", "<p>foo<br />\nbar</p>\n"},
+		{"foo\\\n     bar\n", "<p>foo<br />\nbar</p>\n"},
+		{"*foo  \nbar*\n", "<p><em>foo<br />\nbar</em></p>\n"},
+		{"*foo\\\nbar*\n", "<p><em>foo<br />\nbar</em></p>\n"},
+		{"`code  \nspan`\n", "<p><code>code   span</code></p>\n"},
+		{"`code\\\nspan`\n", "<p><code>code\\ span</code></p>\n"},
+		{"foo\\\\\nbar\n", "<p>foo\\\nbar</p>\n"},
+		{"foo\\\n", "<p>foo\\</p>\n"},
+		{"foo  \n", "<p>foo</p>\n"},
+		{"### foo\\\n", "<h3>foo\\</h3>\n"},
+		{"foo \nbar\n", "<p>foo\nbar</p>\n"},
+		// A backslash is only a hard break if another line of the paragraph
+		// follows, even without a final newline.
+		{"foo\\", "<p>foo\\</p>\n"},
+		{"foo\\\nbar\\", "<p>foo<br />\nbar\\</p>\n"},
+		{"foo\\\n\nbar\n", "<p>foo\\</p>\n<p>bar</p>\n"},
+		{"> foo\\\n\n> bar\\\n", "<blockquote>\n<p>foo\\</p>\n</blockquote>\n<blockquote>\n<p>bar\\</p>\n</blockquote>\n"},
+		{"- foo\\\n- bar\n", "<ul>\n<li>foo\\</li>\n<li>bar</li>\n</ul>\n"},
+		{"foo\\\r\nbar\r\n", "<p>foo<br />\nbar</p>\n"},
+		// Backslashes in autolinks are literal.
+		{"<http://a\\b>\n", "<p><a href=\"http://a%5Cb\">http://a\\b</a></p>\n"},
+	})
+}
+
+func TestBackslashEscapes(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"\\*not emphasis\\*\n", "<p>*not emphasis*</p>\n"},
+		{"\\_not emphasis\\_\n", "<p>_not emphasis_</p>\n"},
+		{"\\[not a link\\](/foo)\n", "<p>[not a link](/foo)</p>\n"},
+		{"\\`not code\\`\n", "<p>`not code`</p>\n"},
+		{"\\\\*emphasis*\n", "<p>\\<em>emphasis</em></p>\n"},
+		{"\\# not a header\n", "<p># not a header</p>\n"},
+		{"\\!\\\"\\#\\$\\%\\&\\'\\(\\)\\*\\+\\,\\-\\.\\/\\:\\;\\<\\=\\>\\?\\@\\[\\\\\\]\\^\\_\\`\\{\\|\\}\\~\n",
+			"<p>!&quot;#$%&amp;'()*+,-./:;&lt;=&gt;?@[\\]^_`{|}~</p>\n"},
+		{"\\a\\b \\→\n", "<p>\\a\\b \\→</p>\n"},
+		{"`` \\[\\` ``\n", "<p><code>\\[\\`</code></p>\n"},
+		{"    \\[\\]\n", "<pre><code>\\[\\]\n</code></pre>\n"},
+		{"~~~\n\\[\\]\n~~~\n", "<pre><code>\\[\\]\n</code></pre>\n"},
+		{"[foo](/bar\\* \"ti\\*tle\")\n", "<p><a href=\"/bar*\" title=\"ti*tle\">foo</a></p>\n"},
+		{"``` foo\\+bar\nfoo\n```\n", "<pre><code class=\"language-foo+bar\">foo\n</code></pre>\n"},
+	})
+}
+
+func TestEntities(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"&nbsp; &amp; &copy; &AElig; &Dcaron;\n", "<p>  &amp; © Æ Ď</p>\n"},
+		{"&#35; &#1234; &#992; &#0;\n", "<p># Ӓ Ϡ �</p>\n"},
+		{"&#X22; &#XD06; &#xcab;\n", "<p>&quot; ആ ಫ</p>\n"},
+		{"&#1114112; &#xD800;\n", "<p>� �</p>\n"},
+		{"&#87654321; &#x1234567;\n", "<p>&amp;#87654321; &amp;#x1234567;</p>\n"},
+		{"&nbsp &x; &#; &#x; &#-1; &hi?;\n", "<p>&amp;nbsp &amp;x; &amp;#; &amp;#x; &amp;#-1; &amp;hi?;</p>\n"},
+		{"&MadeUpEntity;\n", "<p>&amp;MadeUpEntity;</p>\n"},
+		{"&amp;copy;\n", "<p>&amp;copy;</p>\n"},
+		{"`f&ouml;&ouml;`\n", "<p><code>f&amp;ouml;&amp;ouml;</code></p>\n"},
+		{"    f&ouml;f&ouml;\n", "<pre><code>f&amp;ouml;f&amp;ouml;\n</code></pre>\n"},
+		// Entities are decoded in link destinations and titles before the
+		// destination is URL-encoded, and in info strings.
+		{"[foo](/f&ouml;&ouml; \"f&ouml;&ouml;\")\n", "<p><a href=\"/f%C3%B6%C3%B6\" title=\"föö\">foo</a></p>\n"},
+		{"[foo]\n\n[foo]: /f&ouml;&ouml; \"f&#246;&#xF6;\"\n", "<p><a href=\"/f%C3%B6%C3%B6\" title=\"föö\">foo</a></p>\n"},
+		{"![foo](/a&amp;b \"&#34;&quot;\")\n", "<p><img src=\"/a&amp;b\" alt=\"foo\" title=\"&quot;&quot;\" /></p>\n"},
+		{"[foo](/a\\&amp;b &madeup;)\n", "<p>[foo](/a&amp;amp;b &amp;madeup;)</p>\n"},
+		{"[foo](/a\\&amp;b '&madeup;')\n", "<p><a href=\"/a&amp;amp;b\" title=\"&amp;madeup;\">foo</a></p>\n"},
+		{"``` f&ouml;&ouml;\nfoo\n```\n", "<pre><code class=\"language-föö\">foo\n</code></pre>\n"},
+	})
+}
+
+func TestAutolinks(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"<http://foo.bar.baz>\n", "<p><a href=\"http://foo.bar.baz\">http://foo.bar.baz</a></p>\n"},
+		{"<http://foo.bar.baz/test?q=hello&id=22&boolean>\n",
+			"<p><a href=\"http://foo.bar.baz/test?q=hello&amp;id=22&amp;boolean\">http://foo.bar.baz/test?q=hello&amp;id=22&amp;boolean</a></p>\n"},
+		{"<ftp://foo.bar/baz>\n", "<p><a href=\"ftp://foo.bar/baz\">ftp://foo.bar/baz</a></p>\n"},
+		{"<MAILTO:FOO@BAR.BAZ>\n", "<p><a href=\"MAILTO:FOO@BAR.BAZ\">MAILTO:FOO@BAR.BAZ</a></p>\n"},
+		{"<a+b+c:d>\n", "<p><a href=\"a+b+c:d\">a+b+c:d</a></p>\n"},
+		{"<http://example.com/\\[\\>\n", "<p><a href=\"http://example.com/%5C%5B%5C\">http://example.com/\\[\\</a></p>\n"},
+		{"<foo@bar.example.com>\n", "<p><a href=\"mailto:foo@bar.example.com\">foo@bar.example.com</a></p>\n"},
+		{"<foo+special@Bar.baz-bar0.com>\n", "<p><a href=\"mailto:foo+special@Bar.baz-bar0.com\">foo+special@Bar.baz-bar0.com</a></p>\n"},
+		{"<foo\\+@bar.example.com>\n", "<p>&lt;foo+@bar.example.com&gt;</p>\n"},
+		{"< not a link>\n", "<p>&lt; not a link&gt;</p>\n"},
+		{"<http://foo.bar/baz bim>\n", "<p>&lt;http://foo.bar/baz bim&gt;</p>\n"},
+		{"<>\n", "<p>&lt;&gt;</p>\n"},
+		{"<m:abc>\n", "<p>&lt;m:abc&gt;</p>\n"},
+		{"<foo.bar.baz>\n", "<p>&lt;foo.bar.baz&gt;</p>\n"},
+		{"http://example.com\n", "<p>http://example.com</p>\n"},
+		{"*<http://a.b/*>*\n", "<p><em><a href=\"http://a.b/*\">http://a.b/*</a></em></p>\n"},
+		{"[foo<http://example.com/?search=](uri)>\n",
+			"<p>[foo<a href=\"http://example.com/?search=%5D(uri)\">http://example.com/?search=](uri)</a></p>\n"},
+	})
+}
+
+func TestRawHTML(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"<a><bab><c2c>\n", "<p><a><bab><c2c></p>\n"},
+		{"<a/><b2/>\n", "<p><a/><b2/></p>\n"},
+		{"<span class=\"x\" data-y='z' hidden>foo</span>\n", "<p><span class=\"x\" data-y='z' hidden>foo</span></p>\n"},
+		{"<a  /><b2\ndata=\"foo\" >\n", "<p><a  /><b2\ndata=\"foo\" ></p>\n"},
+		{"Foo <responsive-image src=\"foo.jpg\" />\n", "<p>Foo <responsive-image src=\"foo.jpg\" /></p>\n"},
+		{"<33> <__>\n", "<p>&lt;33&gt; &lt;__&gt;</p>\n"},
+		{"<a h*#ref=\"hi\">\n", "<p>&lt;a h*#ref=&quot;hi&quot;&gt;</p>\n"},
+		{"<a href='bar'title=title>\n", "<p>&lt;a href='bar'title=title&gt;</p>\n"},
+		{"< foo>\n", "<p>&lt; foo&gt;</p>\n"},
+		{"</a></foo >\n", "<p></a></foo ></p>\n"},
+		{"</a href=\"foo\">\n", "<p>&lt;/a href=&quot;foo&quot;&gt;</p>\n"},
+		{"foo <!-- this is a --\ncomment - with hyphens -->\n", "<p>foo <!-- this is a --\ncomment - with hyphens --></p>\n"},
+		{"foo <!--> foo -->\n", "<p>foo <!--> foo --&gt;</p>\n"},
+		{"foo <?php echo $a; ?>\n", "<p>foo <?php echo $a; ?></p>\n"},
+		{"foo <!ELEMENT br EMPTY>\n", "<p>foo <!ELEMENT br EMPTY></p>\n"},
+		{"foo <![CDATA[>&<]]>\n", "<p>foo <![CDATA[>&<]]></p>\n"},
+		{"foo <a href=\"&ouml;\">\n", "<p>foo <a href=\"&ouml;\"></p>\n"},
+		{"<a href=\"`\">`\n", "<p><a href=\"`\">`</p>\n"},
+		{"*<a href=\"*\">*\n", "<p><em><a href=\"*\"></em></p>\n"},
+		{"![<b>foo</b>](/url)\n", "<p><img src=\"/url\" alt=\"&lt;b&gt;foo&lt;/b&gt;\" /></p>\n"},
+	})
+}
@@ -0,0 +1,123 @@
+package commonmark
+
+import (
+	"encoding/json"
+)
+
+// jsonNode is the JSON representation of a Node, as produced by
+// ParseDocumentJSON. Properties that do not apply to the type of the node are
+// left out, as are false booleans and empty ids, info strings and alignments.
+type jsonNode struct {
+	Type           string      `json:"type"`
+	Literal        *string     `json:"literal,omitempty"`
+	Level          int         `json:"level,omitempty"`
+	ID             string      `json:"id,omitempty"`
+	Info           string      `json:"info,omitempty"`
+	Fenced         bool        `json:"fenced,omitempty"`
+	Display        bool        `json:"display,omitempty"`
+	Destination    *string     `json:"destination,omitempty"`
+	Title          *string     `json:"title,omitempty"`
+	Ordered        bool        `json:"ordered,omitempty"`
+	BulletChar     string      `json:"bulletChar,omitempty"`
+	Delimiter      string      `json:"delimiter,omitempty"`
+	ListStart      *int        `json:"listStart,omitempty"`
+	Tight          bool        `json:"tight,omitempty"`
+	Task           bool        `json:"task,omitempty"`
+	Checked        bool        `json:"checked,omitempty"`
+	Alignment      string      `json:"alignment,omitempty"`
+	Label          string      `json:"label,omitempty"`
+	FootnoteNumber int         `json:"footnoteNumber,omitempty"`
+	FootnoteIndex  int         `json:"footnoteIndex,omitempty"`
+	NumReferences  int         `json:"numReferences,omitempty"`
+	StartPosition  Position    `json:"startPosition"`
+	EndPosition    Position    `json:"endPosition"`
+	Children       []*jsonNode `json:"children,omitempty"`
+}
+
+// ParseDocumentJSON parses text formatted in CommonMark, like ParseDocument,
+// and returns the tree of nodes as JSON, for debugging or for use by other
+// tools. Each node is an object with its "type", as given by NodeType.String,
+// its "startPosition" and "endPosition", which are objects with a "line",
+// "column" and "offset", and its "children", if it has any. Depending on the
+// type, a node has the other properties of a Node, named as their accessors
+// in camelCase, such as "literal" for Text and "destination" and "title" for
+// Link nodes. The start number of an ordered list is named "listStart", and
+// bullet characters and delimiters are strings.
+func ParseDocumentJSON(markdown []byte) ([]byte, error) {
+	root, err := ParseDocument(markdown)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(nodeToJSON(root))
+}
+
+// nodeToJSON converts a node and its descendants to their JSON
+// representation.
+func nodeToJSON(n *Node) *jsonNode {
+	j := &jsonNode{
+		Type:          n.Type.String(),
+		StartPosition: n.start,
+		EndPosition:   n.end,
+	}
+	switch n.Type {
+	case Text, Code, HTMLInline, HTMLBlock:
+		j.Literal = jsonString(n.literal)
+	case Math:
+		j.Literal = jsonString(n.literal)
+		j.Display = n.display
+	case CodeBlock:
+		j.Literal = jsonString(n.literal)
+		j.Info = string(n.info)
+		j.Fenced = n.fenced
+	case Heading:
+		j.Level = n.level
+		j.ID = n.id
+	case Link, Image:
+		j.Destination = jsonString(n.destination)
+		if n.title != nil {
+			j.Title = jsonString(n.title)
+		}
+	case List, Item:
+		j.Ordered = n.Ordered()
+		if n.Ordered() {
+			j.Delimiter = string(n.Delimiter())
+		} else {
+			j.BulletChar = string(n.BulletChar())
+		}
+		if n.Type == List {
+			if n.Ordered() {
+				start := n.Start()
+				j.ListStart = &start
+			}
+			j.Tight = n.tight
+		} else {
+			j.Task = n.task
+			j.Checked = n.checked
+		}
+	case DefinitionDescription:
+		j.Tight = n.tight
+	case TableCell:
+		j.Alignment = n.alignment
+	case FootnoteDefinition:
+		j.Label = string(n.label)
+		j.ID = n.id
+		j.FootnoteNumber = n.footnoteNumber
+		j.NumReferences = n.numReferences
+	case FootnoteReference:
+		j.Label = string(n.label)
+		j.ID = n.id
+		j.FootnoteNumber = n.footnoteNumber
+		j.FootnoteIndex = n.footnoteIndex
+	}
+	for child := n.firstChild; child != nil; child = child.next {
+		j.Children = append(j.Children, nodeToJSON(child))
+	}
+	return j
+}
+
+// jsonString returns data as a string, for a property that is always present
+// even if it is empty.
+func jsonString(data []byte) *string {
+	s := string(data)
+	return &s
+}
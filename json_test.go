@@ -0,0 +1,45 @@
+package commonmark
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseDocumentJSON(t *testing.T) {
+	input := "# *a*\n\n```go\nb\n```\n"
+	expected := `{"type":"Document","startPosition":{"line":1,"column":1,"offset":0},"endPosition":{"line":5,"column":4,"offset":18},"children":[` +
+		`{"type":"Heading","level":1,"startPosition":{"line":1,"column":1,"offset":0},"endPosition":{"line":1,"column":6,"offset":5},"children":[` +
+		`{"type":"Emph","startPosition":{"line":1,"column":3,"offset":2},"endPosition":{"line":1,"column":6,"offset":5},"children":[` +
+		`{"type":"Text","literal":"a","startPosition":{"line":1,"column":4,"offset":3},"endPosition":{"line":1,"column":5,"offset":4}}]}]},` +
+		`{"type":"CodeBlock","literal":"b\n","info":"go","fenced":true,"startPosition":{"line":3,"column":1,"offset":7},"endPosition":{"line":5,"column":4,"offset":18}}]}`
+	actual, err := ParseDocumentJSON([]byte(input))
+	if err != nil {
+		t.Fatalf("ParseDocumentJSON returned error: %s", err)
+	}
+	if string(actual) != expected {
+		t.Errorf("incorrect JSON for %q:\n%s\nexpected:\n%s", input, actual, expected)
+	}
+}
+
+func TestParseDocumentJSONProperties(t *testing.T) {
+	input := "0) [a]() ![b](/c \"\")\n"
+	actual, err := ParseDocumentJSON([]byte(input))
+	if err != nil {
+		t.Fatalf("ParseDocumentJSON returned error: %s", err)
+	}
+	var root jsonNode
+	if err := json.Unmarshal(actual, &root); err != nil {
+		t.Fatalf("ParseDocumentJSON returned invalid JSON %s: %s", actual, err)
+	}
+	list := root.Children[0]
+	if list.Type != "List" || !list.Ordered || list.Delimiter != ")" || list.ListStart == nil || *list.ListStart != 0 || !list.Tight {
+		t.Errorf("incorrect list in %s", actual)
+	}
+	inlines := list.Children[0].Children[0].Children
+	if link := inlines[0]; link.Type != "Link" || link.Destination == nil || *link.Destination != "" || link.Title != nil {
+		t.Errorf("incorrect link in %s", actual)
+	}
+	if image := inlines[2]; image.Type != "Image" || *image.Destination != "/c" || image.Title == nil || *image.Title != "" {
+		t.Errorf("incorrect image in %s", actual)
+	}
+}
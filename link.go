@@ -0,0 +1,521 @@
+package commonmark
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// linkReference is the destination and title of a link, as defined by a link
+// reference definition.
+type linkReference struct {
+	destination []byte
+	title       []byte
+}
+
+// referenceMap maps normalized link labels to their definitions.
+type referenceMap map[string]*linkReference
+
+// Reference is the destination and title of a link, for
+// Options.ReferenceDefinitions.
+type Reference struct {
+	// Destination is the URL of the link, as it would appear in a link
+	// reference definition, but without backslash escapes or entities.
+	Destination string
+	// Title is the title of the link, or empty if it has none.
+	Title string
+}
+
+// addReferenceDefinitions adds the definitions to the map, except those whose
+// labels are already defined. If several labels normalize to the same key,
+// the one that sorts first is used.
+func addReferenceDefinitions(references referenceMap, definitions map[string]Reference) {
+	labels := make([]string, 0, len(definitions))
+	for label := range definitions {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		key := normalizeLabel([]byte(label))
+		if _, ok := references[key]; ok || key == "" {
+			continue
+		}
+		ref := &linkReference{destination: []byte(definitions[label].Destination)}
+		if title := definitions[label].Title; title != "" {
+			ref.title = []byte(title)
+		}
+		references[key] = ref
+	}
+}
+
+// parseReferenceDefinition parses a link reference definition at the start of
+// data. It returns the raw label, the definition, and the number of bytes
+// consumed including the final newline, which is 0 if data does not start with
+// a valid definition.
+func parseReferenceDefinition(data []byte) (label []byte, ref *linkReference, length int) {
+	label, pos := parseLinkLabel(data)
+	if pos == 0 || pos >= len(data) || data[pos] != ':' {
+		return nil, nil, 0
+	}
+	pos++
+	pos += skipSpacesAndNewline(data[pos:])
+
+	rawDestination, n, ok := parseLinkDestination(data[pos:])
+	// An empty destination is only allowed in angle brackets.
+	if !ok || n == 0 {
+		return nil, nil, 0
+	}
+	pos += n
+	destinationEnd := pos
+
+	// "The title must be separated from the link destination by whitespace."
+	if space := skipSpacesAndNewline(data[pos:]); space > 0 {
+		if title, n := parseLinkTitle(data[pos+space:]); n > 0 {
+			// "No further non-whitespace characters may occur on the line."
+			if end := lineEnd(data, pos+space+n); end > 0 {
+				return label, &linkReference{unescapeString(rawDestination), unescapeString(title)}, end
+			}
+		}
+	}
+
+	// Without a valid title, the definition ends after the destination, which
+	// must be at the end of a line.
+	if end := lineEnd(data, destinationEnd); end > 0 {
+		return label, &linkReference{unescapeString(rawDestination), nil}, end
+	}
+	return nil, nil, 0
+}
+
+// parseLinkLabel parses a link label at the start of data. It returns the
+// label without the enclosing brackets, and the number of bytes consumed,
+// which is 0 if data does not start with a valid label.
+//
+// "A link label begins with a left bracket ([) and ends with the first right
+// bracket (]) that is not backslash-escaped. Between these brackets there
+// must be at least one non-whitespace character. Unescaped square bracket
+// characters are not allowed inside the opening and closing square brackets
+// of link labels. A link label can have at most 999 characters inside the
+// square brackets."
+func parseLinkLabel(data []byte) (label []byte, length int) {
+	if len(data) == 0 || data[0] != '[' {
+		return nil, 0
+	}
+	for i := 1; i < len(data) && i <= 1000; i++ {
+		switch data[i] {
+		case '\\':
+			if i+1 < len(data) && isASCIIPunct(data[i+1]) {
+				i++
+			}
+		case '[':
+			return nil, 0
+		case ']':
+			label = data[1:i]
+			if len(bytes.TrimSpace(label)) == 0 {
+				return nil, 0
+			}
+			return label, i + 1
+		}
+	}
+	return nil, 0
+}
+
+// normalizeLabel returns the key under which a link label is stored in a
+// referenceMap.
+//
+// "One label matches another just in case their normalized forms are equal.
+// To normalize a label, strip off the opening and closing brackets, perform
+// the Unicode case fold, strip leading and trailing whitespace and collapse
+// consecutive internal whitespace to a single space." Like the reference
+// implementation, only ASCII whitespace counts; a non-breaking space must
+// match exactly.
+func normalizeLabel(label []byte) string {
+	folded := strings.ToLower(strings.ToUpper(string(label)))
+	return strings.Join(strings.FieldsFunc(folded, isLabelSpace), " ")
+}
+
+func isLabelSpace(r rune) bool {
+	switch r {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	}
+	return false
+}
+
+// skipSpacesAndNewline returns the number of leading spaces in data,
+// including at most one newline.
+func skipSpacesAndNewline(data []byte) int {
+	var i int
+	newline := false
+	for i < len(data) && (data[i] == ' ' || data[i] == '\n' && !newline) {
+		if data[i] == '\n' {
+			newline = true
+		}
+		i++
+	}
+	return i
+}
+
+// lineEnd returns the position just after the end of the line in data that
+// contains position pos, if only spaces follow pos on that line. Otherwise,
+// it returns 0.
+func lineEnd(data []byte, pos int) int {
+	for ; pos < len(data); pos++ {
+		switch data[pos] {
+		case ' ':
+		case '\n':
+			return pos + 1
+		default:
+			return 0
+		}
+	}
+	return pos
+}
+
+// parseInlineLinkTail parses the part of an inline link that follows the link
+// text, starting with the opening parenthesis. It returns the unescaped
+// destination and title, and the number of bytes consumed. If data does not
+// start with a valid link tail, the returned length is 0.
+func parseInlineLinkTail(data []byte) (destination, title []byte, length int) {
+	if len(data) == 0 || data[0] != '(' {
+		return nil, nil, 0
+	}
+	pos := 1 + skipLinkWhitespace(data[1:])
+
+	rawDestination, n, ok := parseLinkDestination(data[pos:])
+	if !ok {
+		return nil, nil, 0
+	}
+	pos += n
+
+	// "The title [...] separated from the link destination by whitespace"
+	var rawTitle []byte
+	if space := skipLinkWhitespace(data[pos:]); space > 0 {
+		pos += space
+		if t, n := parseLinkTitle(data[pos:]); n > 0 {
+			rawTitle = t
+			pos += n
+			pos += skipLinkWhitespace(data[pos:])
+		}
+	}
+
+	if pos >= len(data) || data[pos] != ')' {
+		return nil, nil, 0
+	}
+	return unescapeString(rawDestination), unescapeString(rawTitle), pos + 1
+}
+
+// skipLinkWhitespace returns the number of leading spaces and newlines in
+// data.
+func skipLinkWhitespace(data []byte) int {
+	var i int
+	for i < len(data) && (data[i] == ' ' || data[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+// parseLinkDestination parses a link destination at the start of data. It
+// returns the raw destination, without any enclosing angle brackets, and the
+// number of bytes consumed. The destination may be empty.
+func parseLinkDestination(data []byte) (destination []byte, length int, ok bool) {
+	if len(data) > 0 && data[0] == '<' {
+		// "a sequence of zero or more characters between an opening < and a
+		// closing > that contains no line breaks or unescaped < or >
+		// characters"
+		for i := 1; i < len(data); i++ {
+			switch data[i] {
+			case '\\':
+				if i+1 < len(data) && isASCIIPunct(data[i+1]) {
+					i++
+				}
+			case '\n', '<':
+				return nil, 0, false
+			case '>':
+				return data[1:i], i + 1, true
+			}
+		}
+		return nil, 0, false
+	}
+
+	// "a nonempty sequence of characters that does not start with <, does not
+	// include ASCII control characters or space character, and includes
+	// parentheses only if (a) they are backslash-escaped or (b) they are part
+	// of a balanced pair of unescaped parentheses."
+	var depth int
+	var i int
+loop:
+	for ; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data) && isASCIIPunct(data[i+1]):
+			i++
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				break loop
+			}
+			depth--
+		case c <= ' ' || c == 0x7f:
+			break loop
+		}
+	}
+	if depth != 0 {
+		return nil, 0, false
+	}
+	return data[:i], i, true
+}
+
+// parseLinkTitle parses a link title at the start of data. It returns the raw
+// title, without the enclosing quotes or parentheses, and the number of bytes
+// consumed, which is 0 if there is no valid title.
+func parseLinkTitle(data []byte) (title []byte, length int) {
+	if len(data) == 0 {
+		return nil, 0
+	}
+	// "A link title consists of either a sequence of zero or more characters
+	// between straight double-quote characters ("), [...] between straight
+	// single-quote characters ('), [...] or between matching parentheses
+	// ((...)), including a ( or ) character only if it is backslash-escaped."
+	var closer byte
+	switch data[0] {
+	case '"':
+		closer = '"'
+	case '\'':
+		closer = '\''
+	case '(':
+		closer = ')'
+	default:
+		return nil, 0
+	}
+	for i := 1; i < len(data); i++ {
+		switch {
+		case data[i] == '\\' && i+1 < len(data) && isASCIIPunct(data[i+1]):
+			i++
+		case data[i] == closer:
+			return data[1:i], i + 1
+		case data[0] == '(' && data[i] == '(':
+			return nil, 0
+		}
+	}
+	return nil, 0
+}
+
+// unescapeString returns data with backslash escapes replaced by the
+// characters they escape, and entity and numeric character references by the
+// characters they represent. It returns data itself if there is nothing to
+// replace.
+//
+// "Entity and numeric character references are recognized in any context
+// besides code spans or code blocks, including URLs, link titles, and fenced
+// code block info strings."
+func unescapeString(data []byte) []byte {
+	if bytes.IndexAny(data, "\\&") < 0 {
+		return data
+	}
+	output := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		switch {
+		case data[i] == '\\' && i+1 < len(data) && isASCIIPunct(data[i+1]):
+			i++
+		case data[i] == '&':
+			if codepoints, length := parseEntity(data[i:]); length > 0 {
+				output = append(output, codepoints...)
+				i += length - 1
+				continue
+			}
+		}
+		output = append(output, data[i])
+	}
+	return output
+}
+
+// "An absolute URI [...] consists of a scheme followed by a colon (:)
+// followed by zero or more characters other than ASCII control characters,
+// space, <, and >. [...] A scheme is any sequence of 2–32 characters beginning
+// with an ASCII letter and followed by any combination of ASCII letters,
+// digits, or the symbols plus (”+”), period (”.”), or hyphen (”-”)."
+var uriAutolinkRe = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\x00-\x20<>\x7f]*)>`)
+
+// "An email address [...] is anything that matches the non-normative regex
+// from the HTML5 spec."
+var emailAutolinkRe = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+
+// parseAutolink parses an autolink at the start of data, which must start
+// with '<'. It returns the link and the number of bytes consumed, which is 0
+// if data does not start with an autolink.
+//
+// "Autolinks are absolute URIs and email addresses inside < and >. They are
+// parsed as links, with the URL or email address as the link label."
+func parseAutolink(data []byte) (*link, int) {
+	if m := uriAutolinkRe.FindSubmatch(data); m != nil {
+		return &link{
+			children:    []Inline{&stringInline{content: m[1]}},
+			destination: m[1],
+		}, len(m[0])
+	}
+	if m := emailAutolinkRe.FindSubmatch(data); m != nil {
+		return &link{
+			children:    []Inline{&stringInline{content: m[1]}},
+			destination: append([]byte("mailto:"), m[1]...),
+		}, len(m[0])
+	}
+	return nil, 0
+}
+
+// extendedURLRe matches an extended www autolink or URL autolink, before
+// trailing punctuation is removed. The second group is the domain.
+//
+// "An extended www autolink will be recognized when the text www. is found
+// followed by a valid domain." "An extended url autolink will be recognised
+// when one of the schemes http://, or https://, followed by a valid domain,
+// then zero or more non-space non-< characters according to extended autolink
+// path validation."
+var extendedURLRe = regexp.MustCompile(`^(www\.|https?://)([a-zA-Z0-9_-]+(?:\.[a-zA-Z0-9_-]+)*)[^\s<]*`)
+
+// "An extended email autolink will be recognised when an email address is
+// recognised within any text node. Email addresses are recognised according to
+// the following rules: One ore more characters which are alphanumeric, or .,
+// -, _, or +. An @ symbol. One or more characters which are alphanumeric, or -
+// or _, separated by periods (.). There must be at least one period."
+var extendedEmailRe = regexp.MustCompile(`^[a-zA-Z0-9._+-]+@[a-zA-Z0-9_-]+(?:\.[a-zA-Z0-9_-]+)+`)
+
+var trailingEntityRe = regexp.MustCompile(`&[a-zA-Z0-9]+;$`)
+
+// parseExtendedAutolink parses an extended autolink of GitHub Flavored
+// Markdown at the start of data. It returns the link and the number of bytes
+// consumed, which is 0 if data does not start with an extended autolink.
+func parseExtendedAutolink(data []byte) (*link, int) {
+	if m := extendedURLRe.FindSubmatch(data); m != nil {
+		// "A valid domain consists of segments of alphanumeric characters,
+		// underscores (_) and hyphens (-) separated by periods (.). There must
+		// be at least one period, and no underscores may be present in the
+		// last two segments of the domain." Like the reference implementation,
+		// a www autolink does not need a period after the www.
+		segments := bytes.Split(m[2], []byte{'.'})
+		if len(segments) < 2 && string(m[1]) != "www." {
+			return nil, 0
+		}
+		for i := len(segments) - 1; i >= 0 && i >= len(segments)-2; i-- {
+			if bytes.IndexByte(segments[i], '_') >= 0 {
+				return nil, 0
+			}
+		}
+		text := trimExtendedAutolink(m[0])
+		destination := text
+		if string(m[1]) == "www." {
+			// "The scheme http will be inserted automatically."
+			destination = append([]byte("http://"), text...)
+		}
+		return &link{
+			children:    []Inline{&stringInline{content: text}},
+			destination: destination,
+		}, len(text)
+	}
+	if m := extendedEmailRe.Find(data); m != nil {
+		// "The last character must not be one of - or _. If the last
+		// character is ., it is excluded."
+		if c := m[len(m)-1]; c == '-' || c == '_' {
+			return nil, 0
+		}
+		return &link{
+			children:    []Inline{&stringInline{content: m}},
+			destination: append([]byte("mailto:"), m...),
+		}, len(m)
+	}
+	return nil, 0
+}
+
+// trimExtendedAutolink removes trailing punctuation from an extended www
+// autolink or URL autolink.
+//
+// "Trailing punctuation (specifically, ?, !, ., ,, :, *, _, and ~) will not be
+// considered part of the autolink, though they may be included in the interior
+// of the link." "When an autolink ends in ), we scan the entire autolink for
+// the total number of parentheses. If there is a greater number of closing
+// parentheses than opening ones, we don’t consider the unmatched trailing
+// parentheses part of the autolink." "If an autolink ends in a semicolon (;),
+// we check to see if it appears to resemble an entity reference; if the
+// preceding text is & followed by one or more alphanumeric characters. If so,
+// it is excluded from the autolink."
+func trimExtendedAutolink(text []byte) []byte {
+	for len(text) > 0 {
+		switch c := text[len(text)-1]; {
+		case bytes.IndexByte([]byte("?!.,:*_~"), c) >= 0:
+			text = text[:len(text)-1]
+		case c == ')' && bytes.Count(text, []byte{')'}) > bytes.Count(text, []byte{'('}):
+			text = text[:len(text)-1]
+		case c == ';' && trailingEntityRe.Match(text):
+			text = text[:bytes.LastIndexByte(text, '&')]
+		default:
+			return text
+		}
+	}
+	return text
+}
+
+var dangerousURLRe = regexp.MustCompile(`(?i)^(?:javascript|vbscript|file|data):`)
+var safeDataURLRe = regexp.MustCompile(`(?i)^data:image/(?:png|gif|jpeg|webp)`)
+
+// isDangerousURL returns whether the URL uses a scheme that can be used to
+// execute scripts. Data URLs are allowed only for common image types.
+func isDangerousURL(url []byte) bool {
+	return dangerousURLRe.Match(url) && !safeDataURLRe.Match(url)
+}
+
+// normalizeURL percent-encodes all characters in the URL that are not safe to
+// use in a URL as-is. Existing percent-encoded sequences are left alone.
+func normalizeURL(url []byte) []byte {
+	var output []byte
+	for i := 0; i < len(url); i++ {
+		c := url[i]
+		if isURLSafe(c) {
+			output = append(output, c)
+		} else if c == '%' && i+2 < len(url) && isHexDigit(url[i+1]) && isHexDigit(url[i+2]) {
+			output = append(output, url[i:i+3]...)
+			i += 2
+		} else {
+			output = append(output, fmt.Sprintf("%%%02X", c)...)
+		}
+	}
+	return output
+}
+
+// resolveURL resolves a normalized URL against the base URL. Absolute URLs,
+// network-path references such as //example.com/foo, and references within
+// the same document, such as #foo or the empty URL, are returned unchanged,
+// as are URLs that cannot be parsed.
+func resolveURL(ref []byte, base *url.URL) []byte {
+	if len(ref) == 0 || ref[0] == '#' || bytes.HasPrefix(ref, []byte("//")) {
+		return ref
+	}
+	u, err := url.Parse(string(ref))
+	if err != nil || u.IsAbs() {
+		return ref
+	}
+	return []byte(base.ResolveReference(u).String())
+}
+
+// isExternalURL returns whether the normalized URL points to a host other
+// than the given one. Relative URLs, including those starting with / or #,
+// and URLs without a host, such as mailto: URLs, are not external. Hosts are
+// compared without regard to case, and with or without the port.
+func isExternalURL(ref []byte, host string) bool {
+	u, err := url.Parse(string(ref))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return !strings.EqualFold(u.Host, host) && !strings.EqualFold(u.Hostname(), host)
+}
+
+func isURLSafe(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		bytes.IndexByte([]byte(";/?:@&=+$,-_.!~*'()#"), c) >= 0
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -0,0 +1,61 @@
+package commonmark
+
+import (
+	"bytes"
+)
+
+// texMath is TeX math between dollar signs, with Options.Math.
+type texMath struct {
+	span
+	content []byte
+	// display is true for display math between $$, and false for inline math
+	// between single dollar signs.
+	display bool
+}
+
+// parseMath parses math at the start of data, which starts with a $. It
+// returns the math and its length, or a length of 0 if data does not start
+// with math.
+//
+// The rules are those of Pandoc's tex_math_dollars extension: "Anything
+// between two $ characters will be treated as TeX math. The opening $ must
+// have a non-space character immediately to its right, while the closing $
+// must have a non-space character immediately to its left, and must not be
+// followed immediately by a digit. Thus, $20,000 and $30,000 won't parse as
+// math." For display math, "$$ delimiters" are used instead, without these
+// restrictions.
+func parseMath(data []byte) (*texMath, int) {
+	if bytes.HasPrefix(data, []byte("$$")) {
+		end := bytes.Index(data[2:], []byte("$$"))
+		if end <= 0 || len(bytes.TrimSpace(data[2:2+end])) == 0 {
+			return nil, 0
+		}
+		return &texMath{content: data[2 : 2+end], display: true}, end + 4
+	}
+	if len(data) < 2 || isMathSpace(data[1]) {
+		return nil, 0
+	}
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			// A backslash escapes the next character, so that \$ does not
+			// end the math. The backslash stays part of the TeX.
+			i++
+		case '$':
+			if isMathSpace(data[i-1]) {
+				// This $ cannot close the math, and a later one must not
+				// either, or "$5 and $x$" would be taken as math.
+				return nil, 0
+			}
+			if i+1 < len(data) && data[i+1] >= '0' && data[i+1] <= '9' {
+				continue
+			}
+			return &texMath{content: data[1:i]}, i + 1
+		}
+	}
+	return nil, 0
+}
+
+func isMathSpace(c byte) bool {
+	return c == ' ' || c == '\n'
+}
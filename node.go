@@ -0,0 +1,579 @@
+package commonmark
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// NodeType is the type of a Node.
+type NodeType int
+
+// The types of block nodes.
+const (
+	// Document is the root of the tree. Its children are blocks.
+	Document NodeType = iota
+	// BlockQuote is a block quote. Its children are blocks.
+	BlockQuote
+	// List is a bullet or ordered list. Its children are Item nodes.
+	List
+	// Item is a list item. Its children are blocks.
+	Item
+	// CodeBlock is an indented or fenced code block. Its content is in
+	// Literal, and the info string of a fenced code block is in Info.
+	CodeBlock
+	// HTMLBlock is a block of raw HTML. Its content is in Literal.
+	HTMLBlock
+	// Paragraph is a paragraph. Its children are inlines.
+	Paragraph
+	// Heading is an ATX or setext header. Its children are inlines.
+	Heading
+	// HorizontalRule is a horizontal rule.
+	HorizontalRule
+	// Table is a table, if Options.Tables is enabled. Its first child is a
+	// TableHeader node, followed by zero or more TableRow nodes.
+	Table
+	// TableHeader is the header row of a table. Its children are TableCell
+	// nodes.
+	TableHeader
+	// TableRow is a row in the body of a table. Its children are TableCell
+	// nodes.
+	TableRow
+	// TableCell is a cell of a table, with the alignment of its column in
+	// Alignment. Its children are inlines.
+	TableCell
+	// FootnoteDefinition is the definition of a footnote, if
+	// Options.Footnotes is enabled. Its children are blocks. The definitions
+	// that are referenced are the last children of the Document, ordered by
+	// FootnoteNumber.
+	FootnoteDefinition
+	// DefinitionList is a definition list, if Options.DefinitionLists is
+	// enabled. Its children are DefinitionTerm nodes, each followed by more
+	// DefinitionTerm nodes or by DefinitionDescription nodes.
+	DefinitionList
+	// DefinitionTerm is a term in a definition list. Its children are
+	// inlines.
+	DefinitionTerm
+	// DefinitionDescription is the description of the preceding terms in a
+	// definition list. Its children are blocks.
+	DefinitionDescription
+)
+
+// The types of inline nodes.
+const (
+	// Text is plain text, in Literal.
+	Text NodeType = iota + DefinitionDescription + 1
+	// SoftBreak is a soft line break.
+	SoftBreak
+	// HardBreak is a hard line break.
+	HardBreak
+	// Code is a code span. Its content is in Literal.
+	Code
+	// HTMLInline is an inline HTML tag. Its content is in Literal.
+	HTMLInline
+	// Emph is emphasis. Its children are inlines.
+	Emph
+	// Strong is strong emphasis. Its children are inlines.
+	Strong
+	// Link is a link. Its children are the link text.
+	Link
+	// Image is an image. Its children are the image description.
+	Image
+	// Strikethrough is text wrapped in ~~, if Options.Strikethrough is
+	// enabled. Its children are inlines.
+	Strikethrough
+	// FootnoteReference is a reference to a footnote, if Options.Footnotes is
+	// enabled.
+	FootnoteReference
+	// Math is TeX math between dollar signs, if Options.Math is enabled. The
+	// TeX is in Literal. Display tells whether it is display math.
+	Math
+)
+
+var nodeTypeNames = []string{
+	Document:              "Document",
+	BlockQuote:            "BlockQuote",
+	List:                  "List",
+	Item:                  "Item",
+	CodeBlock:             "CodeBlock",
+	HTMLBlock:             "HTMLBlock",
+	Paragraph:             "Paragraph",
+	Heading:               "Heading",
+	HorizontalRule:        "HorizontalRule",
+	Table:                 "Table",
+	TableHeader:           "TableHeader",
+	TableRow:              "TableRow",
+	TableCell:             "TableCell",
+	FootnoteDefinition:    "FootnoteDefinition",
+	DefinitionList:        "DefinitionList",
+	DefinitionTerm:        "DefinitionTerm",
+	DefinitionDescription: "DefinitionDescription",
+	Text:                  "Text",
+	SoftBreak:             "SoftBreak",
+	HardBreak:             "HardBreak",
+	Code:                  "Code",
+	HTMLInline:            "HTMLInline",
+	Emph:                  "Emph",
+	Strong:                "Strong",
+	Link:                  "Link",
+	Image:                 "Image",
+	Strikethrough:         "Strikethrough",
+	FootnoteReference:     "FootnoteReference",
+	Math:                  "Math",
+}
+
+func (t NodeType) String() string {
+	if t >= 0 && int(t) < len(nodeTypeNames) && nodeTypeNames[t] != "" {
+		return nodeTypeNames[t]
+	}
+	return fmt.Sprintf("NodeType(%d)", int(t))
+}
+
+// IsBlock returns whether nodes of this type are blocks, as opposed to
+// inlines.
+func (t NodeType) IsBlock() bool {
+	return t < Text
+}
+
+// Node is a node in the parse tree of a document, as returned by
+// ParseDocument.
+//
+// The children of a node form a doubly linked list. Which other properties are
+// meaningful depends on the Type of the node; the others return their zero
+// value.
+type Node struct {
+	// Type is the type of the node.
+	Type NodeType
+
+	parent     *Node
+	firstChild *Node
+	lastChild  *Node
+	prev       *Node
+	next       *Node
+
+	literal     []byte
+	level       int
+	id          string
+	info        []byte
+	fenced      bool
+	display     bool
+	destination []byte
+	title       []byte
+	listMarker  listMarker
+	tight       bool
+	task        bool
+	checked     bool
+	alignment   string
+	label       []byte
+	// footnoteNumber, footnoteIndex and numReferences describe footnotes.
+	footnoteNumber int
+	footnoteIndex  int
+	numReferences  int
+	// options are the options that a Document node was parsed with.
+	options *Options
+	// lineEnding is the first line ending in the input of a Document node.
+	lineEnding string
+
+	start, end Position
+}
+
+// Parent returns the parent of the node, or nil for the root.
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// FirstChild returns the first child of the node, or nil if there are none.
+func (n *Node) FirstChild() *Node {
+	return n.firstChild
+}
+
+// LastChild returns the last child of the node, or nil if there are none.
+func (n *Node) LastChild() *Node {
+	return n.lastChild
+}
+
+// Prev returns the previous sibling of the node, or nil if there is none.
+func (n *Node) Prev() *Node {
+	return n.prev
+}
+
+// Next returns the next sibling of the node, or nil if there is none.
+func (n *Node) Next() *Node {
+	return n.next
+}
+
+// Children returns the children of the node, in order.
+func (n *Node) Children() []*Node {
+	var children []*Node
+	for child := n.firstChild; child != nil; child = child.next {
+		children = append(children, child)
+	}
+	return children
+}
+
+// Literal returns the content of a Text, Code, HTMLInline, Math, CodeBlock or
+// HTMLBlock node.
+func (n *Node) Literal() []byte {
+	return n.literal
+}
+
+// Level returns the level (1-6) of a Heading node.
+func (n *Node) Level() int {
+	return n.level
+}
+
+// ID returns the id of a Heading node, which is derived from its text if
+// Options.HeadingIDs was enabled when parsing, and empty otherwise. For a
+// FootnoteDefinition or FootnoteReference node, it returns the id of the
+// footnote, which is its label unless that was used by an earlier document
+// converted by ToHTMLBytesMulti.
+func (n *Node) ID() string {
+	return n.id
+}
+
+// Info returns the info string of a fenced CodeBlock node, with backslash
+// escapes and entity references resolved.
+func (n *Node) Info() []byte {
+	return n.info
+}
+
+// Fenced returns whether a CodeBlock node is a fenced code block, as opposed
+// to an indented one.
+func (n *Node) Fenced() bool {
+	return n.fenced
+}
+
+// Display returns whether a Math node is display math, between $$, as opposed
+// to inline math.
+func (n *Node) Display() bool {
+	return n.display
+}
+
+// Destination returns the destination of a Link or Image node.
+func (n *Node) Destination() []byte {
+	return n.destination
+}
+
+// Title returns the title of a Link or Image node, or nil if it has none.
+func (n *Node) Title() []byte {
+	return n.title
+}
+
+// Ordered returns whether a List or Item node is an ordered list (item).
+func (n *Node) Ordered() bool {
+	return n.listMarker.ordered()
+}
+
+// BulletChar returns the bullet character ('-', '+' or '*') of a List or Item
+// node of a bullet list.
+func (n *Node) BulletChar() byte {
+	return n.listMarker.bulletChar
+}
+
+// Delimiter returns the delimiter ('.' or ')') of a List or Item node of an
+// ordered list.
+func (n *Node) Delimiter() byte {
+	return n.listMarker.delimiter
+}
+
+// Start returns the number of the first item of an ordered List node, or the
+// number of an ordered Item node.
+func (n *Node) Start() int {
+	return n.listMarker.start
+}
+
+// Task returns whether an Item node is a task list item, which starts with a
+// checkbox.
+func (n *Node) Task() bool {
+	return n.task
+}
+
+// Checked returns whether the checkbox of a task list Item node is checked.
+func (n *Node) Checked() bool {
+	return n.checked
+}
+
+// Tight returns whether a List or DefinitionDescription node is tight, meaning
+// that its paragraphs are not wrapped in <p> tags in HTML.
+func (n *Node) Tight() bool {
+	return n.tight
+}
+
+// Alignment returns the alignment of the column of a TableCell node: "left",
+// "center", "right", or "" if none was specified.
+func (n *Node) Alignment() string {
+	return n.alignment
+}
+
+// Label returns the label of a FootnoteDefinition or FootnoteReference node.
+func (n *Node) Label() []byte {
+	return n.label
+}
+
+// FootnoteNumber returns the number of the footnote of a FootnoteDefinition or
+// FootnoteReference node. Footnotes are numbered from 1 in order of first
+// reference.
+func (n *Node) FootnoteNumber() int {
+	return n.footnoteNumber
+}
+
+// FootnoteIndex returns the 1-based number of a FootnoteReference node among
+// the references to the same footnote, in document order.
+func (n *Node) FootnoteIndex() int {
+	return n.footnoteIndex
+}
+
+// NumReferences returns the number of references to a FootnoteDefinition
+// node.
+func (n *Node) NumReferences() int {
+	return n.numReferences
+}
+
+// StartPosition returns the position in the input of the first byte of the
+// node.
+func (n *Node) StartPosition() Position {
+	return n.start
+}
+
+// EndPosition returns the position in the input just past the last byte of
+// the node, so that input[n.StartPosition().Offset:n.EndPosition().Offset] is
+// the source of the node. For blocks, this is the end of the last line,
+// excluding the line ending.
+func (n *Node) EndPosition() Position {
+	return n.end
+}
+
+// StartLine returns the 1-based number of the first line of the input that
+// the node spans.
+func (n *Node) StartLine() int {
+	return n.start.Line
+}
+
+// EndLine returns the 1-based number of the last line of the input that the
+// node spans.
+func (n *Node) EndLine() int {
+	return n.end.Line
+}
+
+// AppendChild adds a node as the last child of n. The child must not be part
+// of a tree already.
+func (n *Node) AppendChild(child *Node) {
+	child.parent = n
+	child.prev = n.lastChild
+	if n.lastChild != nil {
+		n.lastChild.next = child
+	} else {
+		n.firstChild = child
+	}
+	n.lastChild = child
+}
+
+// ParseDocument parses text formatted in CommonMark into a tree of nodes. The
+// root of the tree, which is returned, is of type Document. See ToHTMLBytes
+// for details on the input. The tree can be converted to HTML with its WriteTo
+// method, or with Render.
+//
+// The nodes may refer to parts of data, so data must not be modified while the
+// tree is in use.
+func ParseDocument(data []byte) (*Node, error) {
+	return ParseDocumentWithOptions(data, Options{})
+}
+
+// ParseDocumentWithOptions is like ParseDocument, but allows the parsing to be
+// configured. Options that only affect rendering are ignored.
+func ParseDocumentWithOptions(data []byte, options Options) (*Node, error) {
+	doc, err := parse(bytes.NewReader(data), data, &options)
+	if err != nil {
+		return nil, err
+	}
+	n := documentToNode(doc)
+	n.options = &options
+	return n, nil
+}
+
+// documentToNode converts the internal representation of a document, and
+// everything in it, to a Node.
+func documentToNode(doc *document) *Node {
+	n := blockToNode(doc, &doc.sourceMap)
+	n.lineEnding = doc.lineEnding
+	for _, f := range doc.footnotes.referenced {
+		n.AppendChild(blockToNode(f, &doc.sourceMap))
+	}
+	return n
+}
+
+// blockToNode converts the internal representation of a block, and everything
+// in it, to a Node. The source map is used to determine the positions of the
+// nodes.
+func blockToNode(b Block, sourceMap *sourceMap) *Node {
+	n := newBlockNode(b, sourceMap)
+	for _, child := range b.Children() {
+		// Footnote definitions are moved to the end of the document.
+		if _, ok := child.(*footnoteDefinition); ok {
+			continue
+		}
+		n.AppendChild(blockToNode(child, sourceMap))
+	}
+	if inlines := b.base().inlineContent; inlines != nil {
+		appendInlineNodes(n, inlines, b.base(), sourceMap)
+	}
+	return n
+}
+
+// newBlockNode returns a Node for the given block, without any children.
+func newBlockNode(b Block, sourceMap *sourceMap) *Node {
+	n := &Node{}
+	switch t := b.(type) {
+	case *document:
+		n.Type = Document
+	case *blockQuote:
+		n.Type = BlockQuote
+	case *listBlock:
+		n.Type = List
+		n.listMarker = t.listMarker
+		n.tight = t.tight
+	case *listItem:
+		n.Type = Item
+		n.listMarker = t.listMarker
+		n.task = t.task
+		n.checked = t.checked
+	case *indentedCodeBlock:
+		n.Type = CodeBlock
+		n.literal = t.content
+	case *fencedCodeBlock:
+		n.Type = CodeBlock
+		n.literal = t.content
+		n.info = unescapeString(bytes.TrimSpace(t.info))
+		n.fenced = true
+	case *htmlBlock:
+		n.Type = HTMLBlock
+		n.literal = t.content
+	case *paragraph:
+		n.Type = Paragraph
+	case *atxHeader:
+		n.Type = Heading
+		n.level = t.level
+		n.id = t.id
+	case *horizontalRule:
+		n.Type = HorizontalRule
+	case *table:
+		n.Type = Table
+	case *tableRow:
+		if t.header {
+			n.Type = TableHeader
+		} else {
+			n.Type = TableRow
+		}
+	case *tableCell:
+		n.Type = TableCell
+		n.alignment = t.alignment
+	case *definitionList:
+		n.Type = DefinitionList
+	case *definitionTerm:
+		n.Type = DefinitionTerm
+	case *definitionDescription:
+		n.Type = DefinitionDescription
+		n.tight = t.tight
+	case *footnoteDefinition:
+		n.Type = FootnoteDefinition
+		n.label = t.label
+		n.id = t.id
+		n.footnoteNumber = t.number
+		n.numReferences = t.numReferences
+	default:
+		assertf(false, "no Node type for Block type %T", b)
+	}
+	base := b.base()
+	n.start = sourceMap.position(base.startLine, base.startColumn)
+	n.end = sourceMap.lineEnd(base.endLine)
+	return n
+}
+
+// appendInlineNodes converts the internal representation of an inline, and
+// everything in it, to nodes, and appends them to the children of parent. The
+// block that contains the inline is used to determine the positions of the
+// nodes.
+func appendInlineNodes(parent *Node, i Inline, b *block, sourceMap *sourceMap) {
+	if t, ok := i.(*multipleInline); ok {
+		for _, child := range t.children {
+			appendInlineNodes(parent, child, b, sourceMap)
+		}
+		return
+	}
+	n := newInlineNode(i, b, sourceMap)
+	for _, child := range inlineChildren(i) {
+		appendInlineNodes(n, child, b, sourceMap)
+	}
+	parent.AppendChild(n)
+}
+
+// newInlineNode returns a Node for the given inline, which must not be a
+// multipleInline, without any children. The block that contains the inline is
+// used to determine the position of the node.
+func newInlineNode(i Inline, b *block, sourceMap *sourceMap) *Node {
+	n := &Node{}
+	switch t := i.(type) {
+	case *stringInline:
+		n.Type = Text
+		n.literal = t.content
+	case *softLineBreak:
+		n.Type = SoftBreak
+	case *hardLineBreak:
+		n.Type = HardBreak
+	case *codeSpan:
+		n.Type = Code
+		n.literal = t.content
+	case *texMath:
+		n.Type = Math
+		n.literal = t.content
+		n.display = t.display
+	case *rawHTML:
+		n.Type = HTMLInline
+		n.literal = t.content
+	case *emphasis:
+		n.Type = Emph
+	case *strongEmphasis:
+		n.Type = Strong
+	case *strikethrough:
+		n.Type = Strikethrough
+	case *link:
+		n.Type = Link
+		n.destination = t.destination
+		n.title = t.title
+	case *image:
+		n.Type = Image
+		n.destination = t.destination
+		n.title = t.title
+	case *footnoteReference:
+		n.Type = FootnoteReference
+		n.label = t.definition.label
+		n.id = t.definition.id
+		n.footnoteNumber = t.definition.number
+		n.footnoteIndex = t.index
+	default:
+		assertf(false, "no Node type for Inline type %T", i)
+	}
+	if s := i.base(); s.end > s.start {
+		n.start = sourceMap.position(b.contentPosition(s.start))
+		n.end = sourceMap.positionAfter(b.contentPosition(s.end - 1))
+	}
+	return n
+}
+
+// inlineChildren returns the child inlines of the given inline, if any.
+func inlineChildren(i Inline) []Inline {
+	switch t := i.(type) {
+	case *multipleInline:
+		return t.children
+	case *emphasis:
+		return t.children
+	case *strongEmphasis:
+		return t.children
+	case *strikethrough:
+		return t.children
+	case *link:
+		return t.children
+	case *image:
+		return t.children
+	}
+	return nil
+}
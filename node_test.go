@@ -0,0 +1,120 @@
+package commonmark
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// dumpNode returns a compact representation of the tree rooted at n, for use
+// in tests.
+func dumpNode(n *Node) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(n.Type.String())
+	if n.Literal() != nil {
+		fmt.Fprintf(&buffer, " %q", n.Literal())
+	}
+	if n.FirstChild() != nil {
+		buffer.WriteString("(")
+		for child := n.FirstChild(); child != nil; child = child.Next() {
+			if child != n.FirstChild() {
+				buffer.WriteString(", ")
+			}
+			buffer.WriteString(dumpNode(child))
+		}
+		buffer.WriteString(")")
+	}
+	return buffer.String()
+}
+
+func TestParseDocument(t *testing.T) {
+	doc, err := ParseDocument([]byte("# Title\n\nSome *text*\n\n- one\n- two\n\n```go\ncode\n```\n"))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %s", err)
+	}
+	expected := `Document(Heading(Text "Title"), Paragraph(Text "Some ", Emph(Text "text")), ` +
+		`List(Item(Paragraph(Text "one")), Item(Paragraph(Text "two"))), CodeBlock "code\n")`
+	if actual := dumpNode(doc); actual != expected {
+		t.Errorf("incorrect tree\nexpected: %s\nactual:   %s", expected, actual)
+	}
+
+	heading := doc.FirstChild()
+	if heading.Level() != 1 || heading.StartLine() != 1 || heading.EndLine() != 1 {
+		t.Errorf("incorrect heading: level %d, lines %d-%d", heading.Level(), heading.StartLine(), heading.EndLine())
+	}
+	list := heading.Next().Next()
+	if list.Ordered() || !list.Tight() || list.BulletChar() != '-' || len(list.Children()) != 2 {
+		t.Errorf("incorrect list: ordered %v, tight %v, bullet %q, %d items", list.Ordered(), list.Tight(), list.BulletChar(), len(list.Children()))
+	}
+	code := doc.LastChild()
+	if !code.Fenced() || string(code.Info()) != "go" || code.StartLine() != 8 || code.EndLine() != 10 {
+		t.Errorf("incorrect code block: fenced %v, info %q, lines %d-%d", code.Fenced(), code.Info(), code.StartLine(), code.EndLine())
+	}
+	if code.Prev() != list || code.Parent() != doc || doc.Parent() != nil {
+		t.Errorf("incorrect links between nodes")
+	}
+}
+
+func TestFootnoteNodes(t *testing.T) {
+	doc, err := ParseDocumentWithOptions([]byte("> [^b]: B\n\na[^a] b[^b] c[^a]\n\n[^a]: A\n"), Options{Footnotes: true})
+	if err != nil {
+		t.Fatalf("ParseDocumentWithOptions returned error: %s", err)
+	}
+	expected := `Document(BlockQuote, Paragraph(Text "a", FootnoteReference, Text " b", FootnoteReference, Text " c", FootnoteReference), ` +
+		`FootnoteDefinition(Paragraph(Text "A")), FootnoteDefinition(Paragraph(Text "B")))`
+	if actual := dumpNode(doc); actual != expected {
+		t.Errorf("incorrect tree\nexpected: %s\nactual:   %s", expected, actual)
+	}
+
+	var refs []string
+	for n := doc.Children()[1].FirstChild(); n != nil; n = n.Next() {
+		if n.Type == FootnoteReference {
+			refs = append(refs, fmt.Sprintf("%s:%d.%d", n.Label(), n.FootnoteNumber(), n.FootnoteIndex()))
+		}
+	}
+	if actual := fmt.Sprint(refs); actual != "[a:1.1 b:2.1 a:1.2]" {
+		t.Errorf("incorrect references: %s", actual)
+	}
+	a := doc.Children()[2]
+	if string(a.Label()) != "a" || a.FootnoteNumber() != 1 || a.NumReferences() != 2 || a.StartLine() != 5 {
+		t.Errorf("incorrect definition: label %q, number %d, %d references, line %d", a.Label(), a.FootnoteNumber(), a.NumReferences(), a.StartLine())
+	}
+}
+
+func TestNodeTypeString(t *testing.T) {
+	if s := Heading.String(); s != "Heading" {
+		t.Errorf("Heading.String() = %q", s)
+	}
+	if s := NodeType(-1).String(); s != "NodeType(-1)" {
+		t.Errorf("NodeType(-1).String() = %q", s)
+	}
+	if !Paragraph.IsBlock() || Emph.IsBlock() {
+		t.Errorf("incorrect IsBlock")
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "Intro\r\n\r\n## Heading *here*\r\n\r\n> ```go\r\n> code\r\n> ```\r\n"
+	doc, err := ParseDocument([]byte(input))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %s", err)
+	}
+	tests := []struct {
+		node       *Node
+		start, end Position
+		source     string
+	}{
+		{doc.FirstChild().Next(), Position{3, 1, 9}, Position{3, 18, 26}, "## Heading *here*"},
+		{doc.FirstChild().Next().LastChild(), Position{3, 12, 20}, Position{3, 18, 26}, "*here*"},
+		{doc.LastChild(), Position{5, 1, 30}, Position{7, 6, 52}, "> ```go\r\n> code\r\n> ```"},
+		{doc.LastChild().FirstChild(), Position{5, 3, 32}, Position{7, 6, 52}, "```go\r\n> code\r\n> ```"},
+	}
+	for _, test := range tests {
+		start, end := test.node.StartPosition(), test.node.EndPosition()
+		if start != test.start || end != test.end {
+			t.Errorf("%s node at %+v-%+v, expected %+v-%+v", test.node.Type, start, end, test.start, test.end)
+		} else if source := input[start.Offset:end.Offset]; source != test.source {
+			t.Errorf("%s node has source %q, expected %q", test.node.Type, source, test.source)
+		}
+	}
+}
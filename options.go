@@ -0,0 +1,284 @@
+package commonmark
+
+import (
+	"regexp"
+)
+
+// Options configures the parsing of CommonMark and the conversion to HTML.
+//
+// The zero value of Options results in the behaviour described by the
+// CommonMark spec, so fields must be chosen such that false, zero or empty
+// means "do what the spec says". New options may be added in the future;
+// construct Options using field names so that this does not break your code.
+type Options struct {
+	// HardWraps causes soft line breaks in paragraphs to be rendered as hard
+	// line breaks (<br />), like GitHub does in comments.
+	HardWraps bool
+
+	// SoftBreak is written for soft line breaks in paragraphs, instead of a
+	// newline. Set it to " " to keep each paragraph on one line. Empty means
+	// a newline. Hard line breaks and code blocks are not affected, and
+	// neither are soft line breaks with HardWraps.
+	SoftBreak string
+
+	// Safe suppresses raw HTML, which is replaced by an HTML comment, and
+	// removes the destinations of links and images that use potentially
+	// dangerous URL schemes such as javascript:.
+	Safe bool
+
+	// SmartPunctuation converts straight quotes to curly quotes, -- to an en
+	// dash, --- to an em dash and ... to an ellipsis. Whether a quote opens or
+	// closes is determined from the surrounding characters, in the same way as
+	// for emphasis. Code spans, code blocks, raw HTML and link destinations are
+	// not affected, nor are characters escaped with a backslash.
+	SmartPunctuation bool
+
+	// Strikethrough enables the strikethrough extension of GitHub Flavored
+	// Markdown: text wrapped in ~~ is rendered in <del> tags. The tildes
+	// follow the same rules as * for emphasis, except that only runs of
+	// exactly two tildes count. Other tildes are left as they are.
+	Strikethrough bool
+
+	// Tables enables the tables extension of GitHub Flavored Markdown. A
+	// paragraph line of cells separated by pipes (|), followed by a delimiter
+	// row such as |---|:-:|, starts a table that continues until a blank line
+	// or the start of another block.
+	Tables bool
+
+	// TaskLists enables the task list items extension of GitHub Flavored
+	// Markdown: a list item whose first paragraph starts with [ ] or [x] is
+	// rendered with a disabled checkbox, which is checked for [x] or [X].
+	TaskLists bool
+
+	// AutolinkURLs enables the autolink extension of GitHub Flavored
+	// Markdown: URLs starting with http://, https:// or www., and email
+	// addresses, are turned into links even without the angle brackets that
+	// autolinks normally require. Trailing punctuation is not included in the
+	// link.
+	AutolinkURLs bool
+
+	// TagFilter enables the disallowed raw HTML extension of GitHub Flavored
+	// Markdown: in raw HTML, the < of the tags <title>, <textarea>, <style>,
+	// <xmp>, <iframe>, <noembed>, <noframes>, <script> and <plaintext> is
+	// replaced by &lt;, so that they are rendered as text. Other raw HTML is
+	// left alone; see Safe to suppress all of it.
+	TagFilter bool
+
+	// Footnotes enables footnotes: [^label] refers to the footnote defined by
+	// a block starting with [^label]:, whose continuation lines are indented
+	// by four spaces. The footnotes are numbered in order of first reference
+	// and rendered in a list at the end of the document, with links back to
+	// each reference. Footnotes that are not referenced are dropped.
+	Footnotes bool
+
+	// Math enables TeX math, in the way of Pandoc: $...$ is inline math and
+	// $$...$$ is display math. The TeX is written as it is, apart from HTML
+	// escaping, in <span class="math inline">\(...\)</span> or
+	// <span class="math display">\[...\]</span>, for MathJax or KaTeX to
+	// render. To avoid mistaking amounts of money for math, the opening $ of
+	// inline math must not be followed by a space, and the closing $ must not
+	// be preceded by a space or followed by a digit.
+	Math bool
+
+	// DefinitionLists enables definition lists, as in PHP Markdown Extra: each
+	// line of a paragraph followed by a line starting with a colon and a
+	// space becomes a term, and the colon starts a description of the terms,
+	// whose continuation lines are indented to line up with the text after
+	// the colon. A description may contain any blocks. Descriptions that are
+	// preceded by a blank line, or that contain blocks separated by blank
+	// lines, have their paragraphs wrapped in <p> tags.
+	DefinitionLists bool
+
+	// FencedCodeAttributes recognizes info strings of fenced code blocks
+	// that consist of a pandoc-style attribute block, such as
+	// {#example .go .numberLines}, and writes its id and classes as the
+	// attributes of the <code> element, without CodeBlockLanguagePrefix.
+	// Other attributes in the block are ignored. Info strings without braces
+	// still give their first word as the language.
+	FencedCodeAttributes bool
+
+	// CodeBlockLanguagePrefix is written before the language of a fenced
+	// code block, which is the first word of its info string, to form the
+	// class of the <code> element. Empty means "language-", as the spec
+	// suggests, unless NoCodeBlockLanguagePrefix is set.
+	CodeBlockLanguagePrefix string
+
+	// NoCodeBlockLanguagePrefix makes the class of fenced code blocks the
+	// bare language, as in class="go", without a prefix. It has no effect if
+	// CodeBlockLanguagePrefix is set.
+	NoCodeBlockLanguagePrefix bool
+
+	// WrapperTag is the name of an element, such as "div" or "article",
+	// that is wrapped around the whole output. Empty means no wrapper,
+	// unless WrapperClass is set, in which case a <div> is used. A name must
+	// consist of an ASCII letter followed by ASCII letters, digits and
+	// hyphens; if it does not, a <div> is used instead.
+	WrapperTag string
+
+	// WrapperClass is the class of the wrapper element; see WrapperTag.
+	WrapperClass string
+
+	// HTML5 writes void elements such as <br>, <hr> and <img> in HTML5
+	// style, without the slash that XHTML requires, as in <br />.
+	HTML5 bool
+
+	// BaseURL, if not empty, is the URL against which relative link and
+	// image destinations are resolved, following the rules of
+	// net/url.URL.ResolveReference: against http://example.com/docs/, foo
+	// becomes http://example.com/docs/foo and /foo becomes
+	// http://example.com/foo. Absolute URLs, URLs starting with // and
+	// fragments such as #foo are left alone. An invalid BaseURL is ignored.
+	BaseURL string
+
+	// NofollowExternal adds rel="nofollow noopener" to links to other sites,
+	// which are those whose destination is an absolute URL or starts with //,
+	// and has a host other than InternalHost. Relative destinations are never
+	// external, even if they are resolved against a BaseURL.
+	NofollowExternal bool
+
+	// ExternalLinksNewWindow adds target="_blank" to links to other sites, as
+	// defined for NofollowExternal, so that they open in a new window, and
+	// rel="noopener" so that the new page cannot control this one.
+	ExternalLinksNewWindow bool
+
+	// InternalHost is the host name of the site itself, with or without a
+	// port, for NofollowExternal and ExternalLinksNewWindow. Links to it are
+	// not considered external.
+	InternalHost string
+
+	// HeadingIDs gives each heading an id attribute derived from its text,
+	// so that it can be linked to, in the way GitHub does: the text is
+	// lowercased, spaces become hyphens and punctuation is dropped. If several
+	// headings have the same text, -1, -2 and so on are appended to make the
+	// ids unique. The ids are also available as Node.ID.
+	HeadingIDs bool
+
+	// Slugify, if not nil, derives the ids of headings from their text for
+	// HeadingIDs, instead of the GitHub-style algorithm. It is passed the
+	// plain text of the heading, without markup. Numeric suffixes are still
+	// appended to make duplicate ids unique, and headings for which it
+	// returns an empty string get no id.
+	Slugify func(text string) string
+
+	// DisabledInlines is a set of inline constructs that are not recognized.
+	// Their syntax is treated as text instead, and is escaped in the output
+	// where needed. For example, DisabledInlines: Images | RawHTML leaves
+	// ![x](y) and <b> as they are, while still allowing [x](y) links.
+	DisabledInlines InlineSet
+
+	// ReferenceDefinitions holds link reference definitions that are used as
+	// if they were defined in every document, keyed by their labels. For
+	// example, with a definition for "CommonMark", the document [CommonMark]
+	// contains a link. Labels are matched in the same way as in documents.
+	// Definitions in the document itself take precedence.
+	ReferenceDefinitions map[string]Reference
+
+	// ShareReferenceDefinitions makes the link reference definitions of each
+	// document converted by ToHTMLBytesMulti apply to the documents after it
+	// as well. A document's own definitions take precedence.
+	ShareReferenceDefinitions bool
+
+	// TabWidth is the distance between tab stops, for documents that were
+	// written with tabs of a different width. Zero means 4, which is what the
+	// spec prescribes: "Tabs in lines are expanded to spaces, with a tab stop
+	// of 4 characters."
+	TabWidth int
+
+	// MaxNestingDepth limits how deeply block quotes, lists and other
+	// container blocks can be nested, and how many opening brackets of links
+	// and images can be open at once. Markers beyond the limit are treated as
+	// text. This protects against maliciously deep input, which could
+	// otherwise exhaust the stack. Zero means 1000.
+	MaxNestingDepth int
+
+	// LineEnding determines the line endings in the output. The default is
+	// LineEndingLF.
+	LineEnding LineEnding
+
+	// Strict causes constructs that the spec accepts, but that are most
+	// likely mistakes, to be reported as a *ParseError instead of being
+	// converted. These are fenced code blocks without a closing fence, and
+	// HTML blocks such as <pre> or <!-- that are never ended, both of which
+	// would otherwise swallow the rest of the document or their container.
+	Strict bool
+}
+
+// LineEnding is a choice of line ending for the output; see
+// Options.LineEnding.
+type LineEnding int
+
+const (
+	// LineEndingLF ends lines with '\n', regardless of the line endings in
+	// the input.
+	LineEndingLF LineEnding = iota
+	// LineEndingCRLF ends lines with "\r\n", as is customary on Windows.
+	LineEndingCRLF
+	// LineEndingPreserve ends lines in the same way as the first line of the
+	// input that has a line ending, or with '\n' if there is none. The
+	// output cannot reproduce a mixture of different line endings, because
+	// many of its lines have no counterpart in the input.
+	LineEndingPreserve
+)
+
+// InlineSet is a set of inline constructs, for use in Options.DisabledInlines.
+type InlineSet uint
+
+// The inline constructs that can be disabled.
+const (
+	// CodeSpans are spans of text delimited by backticks.
+	CodeSpans InlineSet = 1 << iota
+	// Emphasis is emphasis and strong emphasis with * and _.
+	Emphasis
+	// Links are inline links and reference links, and footnote references.
+	Links
+	// Images are inline images and reference images.
+	Images
+	// Autolinks are URLs and email addresses in angle brackets.
+	Autolinks
+	// RawHTML is inline HTML. HTML blocks are not affected.
+	RawHTML
+)
+
+// disabled returns whether the inline construct is disabled.
+func (options *Options) disabled(inline InlineSet) bool {
+	return options.DisabledInlines&inline != 0
+}
+
+// languagePrefix returns the prefix of the class of fenced code blocks.
+func (options *Options) languagePrefix() string {
+	if options.CodeBlockLanguagePrefix == "" && !options.NoCodeBlockLanguagePrefix {
+		return "language-"
+	}
+	return options.CodeBlockLanguagePrefix
+}
+
+// wrapperTag returns the name of the element that wraps the output, or ""
+// if there is none.
+func (options *Options) wrapperTag() string {
+	switch {
+	case options.WrapperTag == "" && options.WrapperClass == "":
+		return ""
+	case !tagNameRe.MatchString(options.WrapperTag):
+		return "div"
+	}
+	return options.WrapperTag
+}
+
+// tagNameRe matches a valid tag name, as in raw HTML.
+var tagNameRe = regexp.MustCompile(`^` + tagNamePattern + `$`)
+
+// tabStop returns the distance between tab stops.
+func (options *Options) tabStop() int {
+	if options.TabWidth > 0 {
+		return options.TabWidth
+	}
+	return 4
+}
+
+// maxNestingDepth returns the maximum nesting depth of blocks and brackets.
+func (options *Options) maxNestingDepth() int {
+	if options.MaxNestingDepth > 0 {
+		return options.MaxNestingDepth
+	}
+	return 1000
+}
@@ -0,0 +1,725 @@
+package commonmark
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestZeroOptions(t *testing.T) {
+	inputs := []string{
+		"# foo\nbar  \nbaz\n",
+		"- foo\nbar\n\n> <div>\n",
+	}
+	for _, input := range inputs {
+		expected, err := ToHTMLBytes([]byte(input))
+		if err != nil {
+			t.Fatalf("ToHTMLBytes(%q) returned error: %s", input, err)
+		}
+		actual, err := ToHTMLBytesWithOptions([]byte(input), Options{})
+		if err != nil {
+			t.Fatalf("ToHTMLBytesWithOptions(%q) returned error: %s", input, err)
+		}
+		if string(actual) != string(expected) {
+			t.Errorf("ToHTMLBytesWithOptions(%q) = %q, but ToHTMLBytes returned %q", input, actual, expected)
+		}
+	}
+}
+
+func TestHardWraps(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{HardWraps: true}, []conversionTest{
+		{"foo\nbar\n", "<p>foo<br />\nbar</p>\n"},
+		{"foo  \nbar\n", "<p>foo<br />\nbar</p>\n"},
+		{"    foo\n    bar\n", "<pre><code>foo\nbar\n</code></pre>\n"},
+		{"```\nfoo\nbar\n```\n", "<pre><code>foo\nbar\n</code></pre>\n"},
+		{"<div>\nfoo\nbar\n</div>\n", "<div>\nfoo\nbar\n</div>\n"},
+		{"foo\\\nbar\nbaz\n", "<p>foo<br />\nbar<br />\nbaz</p>\n"},
+		{"- foo\n  bar\n", "<ul>\n<li>foo<br />\nbar</li>\n</ul>\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"foo\nbar\n", "<p>foo\nbar</p>\n"},
+		{"foo\\\nbar\nbaz\n", "<p>foo<br />\nbar\nbaz</p>\n"},
+	})
+}
+
+func TestSoftBreak(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{SoftBreak: " "}, []conversionTest{
+		{"foo\nbar\n", "<p>foo bar</p>\n"},
+		{"*foo\nbar*\nbaz\n", "<p><em>foo bar</em> baz</p>\n"},
+		{"foo\\\nbar\nbaz\n", "<p>foo<br />\nbar baz</p>\n"},
+		{"foo  \nbar\n", "<p>foo<br />\nbar</p>\n"},
+		{"    foo\n    bar\n", "<pre><code>foo\nbar\n</code></pre>\n"},
+		{"```\nfoo\nbar\n```\n", "<pre><code>foo\nbar\n</code></pre>\n"},
+		{"<div>\nfoo\nbar\n</div>\n", "<div>\nfoo\nbar\n</div>\n"},
+		{"- foo\n  bar\n", "<ul>\n<li>foo bar</li>\n</ul>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{SoftBreak: " ", HardWraps: true}, []conversionTest{
+		{"foo\nbar\n", "<p>foo<br />\nbar</p>\n"},
+	})
+}
+
+func TestSafe(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{Safe: true}, []conversionTest{
+		{"<script>alert(1)</script>\n", "<!-- raw HTML omitted -->\n"},
+		{"<div>\n*foo*\n\nbar\n", "<!-- raw HTML omitted -->\n<p>bar</p>\n"},
+		{"foo <b onclick=\"alert(1)\">bar</b>\n", "<p>foo <!-- raw HTML omitted -->bar<!-- raw HTML omitted --></p>\n"},
+		{"[x](javascript:alert(1))\n", "<p><a href=\"\">x</a></p>\n"},
+		{"[x](JavaScript:alert(1))\n", "<p><a href=\"\">x</a></p>\n"},
+		{"<vbscript:foo>\n", "<p><a href=\"\">vbscript:foo</a></p>\n"},
+		{"![x](data:text/html;base64,PHNjcmlwdD4=)\n", "<p><img src=\"\" alt=\"x\" /></p>\n"},
+		{"![x](data:image/png;base64,iVBORw0=)\n", "<p><img src=\"data:image/png;base64,iVBORw0=\" alt=\"x\" /></p>\n"},
+		{"[x](http://example.com/)\n", "<p><a href=\"http://example.com/\">x</a></p>\n"},
+		{"[x](/javascript:foo)\n", "<p><a href=\"/javascript:foo\">x</a></p>\n"},
+		{"`<script>`\n", "<p><code>&lt;script&gt;</code></p>\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"<script>alert(1)</script>\n", "<script>alert(1)</script>\n"},
+		{"[x](javascript:alert(1))\n", "<p><a href=\"javascript:alert(1)\">x</a></p>\n"},
+	})
+}
+
+func TestSmartPunctuation(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{SmartPunctuation: true}, []conversionTest{
+		// Quotes.
+		{"\"Hello,\" said the spider. \"'Shelob' is my name.\"\n", "<p>“Hello,” said the spider. “‘Shelob’ is my name.”</p>\n"},
+		{"'A', 'B', and 'C' are letters.\n", "<p>‘A’, ‘B’, and ‘C’ are letters.</p>\n"},
+		{"'He said, \"I want to go.\"'\n", "<p>‘He said, “I want to go.”’</p>\n"},
+		{"\"*foo* 'bar'\"\n", "<p>“<em>foo</em> ‘bar’”</p>\n"},
+		{"\"A paragraph with no closing quote.\n", "<p>“A paragraph with no closing quote.</p>\n"},
+		{"[a]'s b'\n", "<p>[a]’s b’</p>\n"},
+		// Apostrophes.
+		{"Were you alive in the 70's?\n", "<p>Were you alive in the 70’s?</p>\n"},
+		{"'We'll use Jane's boat,' Jenna said.\n", "<p>‘We’ll use Jane’s boat,’ Jenna said.</p>\n"},
+		{"'tis the season to be 'jolly'\n", "<p>’tis the season to be ‘jolly’</p>\n"},
+		// Dashes.
+		{"em---em en--en em --- em en -- en 2--3\n", "<p>em—em en–en em — em en – en 2–3</p>\n"},
+		{"one- two-- three--- four---- five----- six------ seven------- thirteen-------------.\n",
+			"<p>one- two– three— four–– five—– six—— seven—–– thirteen———––.</p>\n"},
+		// Ellipses.
+		{"Ellipses...and...and....\n", "<p>Ellipses…and…and….</p>\n"},
+		// Escaped characters are not converted.
+		{"\\\"not smart\\\" 5\\'8\\\" isn't\n", "<p>&quot;not smart&quot; 5'8&quot; isn’t</p>\n"},
+		{"\\-- \\-\\-\\- \\.\\.\\.\n", "<p>-- --- ...</p>\n"},
+		// Code, raw HTML and URLs are not affected.
+		{"'`\"a\" -- b...`'\n", "<p>‘<code>&quot;a&quot; -- b...</code>’</p>\n"},
+		{"    \"a\" -- b...\n", "<pre><code>&quot;a&quot; -- b...\n</code></pre>\n"},
+		{"```\n'a'\n```\n", "<pre><code>'a'\n</code></pre>\n"},
+		{"<span title=\"a--b\">\"x\"</span>\n", "<p><span title=\"a--b\">“x”</span></p>\n"},
+		{"<http://example.com/a--b...>\n", "<p><a href=\"http://example.com/a--b...\">http://example.com/a--b...</a></p>\n"},
+		{"[\"x\"](/a--b \"c--d\")\n", "<p><a href=\"/a--b\" title=\"c--d\">“x”</a></p>\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"\"a\" 'b' -- c...\n", "<p>&quot;a&quot; 'b' -- c...</p>\n"},
+	})
+}
+
+func TestStrikethrough(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{Strikethrough: true}, []conversionTest{
+		{"~~deleted~~\n", "<p><del>deleted</del></p>\n"},
+		{"foo ~~bar baz~~ qux\n", "<p>foo <del>bar baz</del> qux</p>\n"},
+		{"~~**bold gone**~~\n", "<p><del><strong>bold gone</strong></del></p>\n"},
+		{"**~~bold gone~~**\n", "<p><strong><del>bold gone</del></strong></p>\n"},
+		{"~~a *b ~~c~~ d* e~~\n", "<p><del>a <em>b <del>c</del> d</em> e</del></p>\n"},
+		{"~~*a~~*\n", "<p><del>*a</del>*</p>\n"},
+		{"~~multiple\nlines~~\n", "<p><del>multiple\nlines</del></p>\n"},
+		// Unbalanced or non-double tildes are literal.
+		{"~single~\n", "<p>~single~</p>\n"},
+		{"a ~~~triple~~~\n", "<p>a ~~~triple~~~</p>\n"},
+		{"~~open\n", "<p>~~open</p>\n"},
+		{"close~~\n", "<p>close~~</p>\n"},
+		{"~~a~\n", "<p>~~a~</p>\n"},
+		{"a ~~ b ~~ c\n", "<p>a ~~ b ~~ c</p>\n"},
+		{"\\~~a~~\n", "<p>~~a~~</p>\n"},
+		{"`~~a~~`\n", "<p><code>~~a~~</code></p>\n"},
+		{"![~~a~~](b)\n", "<p><img src=\"b\" alt=\"a\" /></p>\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"~~deleted~~\n", "<p>~~deleted~~</p>\n"},
+	})
+}
+
+func TestTables(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{Tables: true}, []conversionTest{
+		{"| foo | bar |\n| --- | --- |\n| baz | bim |\n",
+			"<table>\n<thead>\n<tr>\n<th>foo</th>\n<th>bar</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>baz</td>\n<td>bim</td>\n</tr>\n</tbody>\n</table>\n"},
+		// Alignments.
+		{"| a | b | c | d |\n| :-- | :-: | --: | --- |\n| 1 | 2 | 3 | 4 |\n",
+			"<table>\n<thead>\n<tr>\n<th align=\"left\">a</th>\n<th align=\"center\">b</th>\n<th align=\"right\">c</th>\n<th>d</th>\n</tr>\n</thead>\n" +
+				"<tbody>\n<tr>\n<td align=\"left\">1</td>\n<td align=\"center\">2</td>\n<td align=\"right\">3</td>\n<td>4</td>\n</tr>\n</tbody>\n</table>\n"},
+		// Leading and trailing pipes are optional, and inlines are parsed.
+		{"abc | *def*\n--|:-\n`bar` | [baz](/url)\n",
+			"<table>\n<thead>\n<tr>\n<th>abc</th>\n<th align=\"left\"><em>def</em></th>\n</tr>\n</thead>\n" +
+				"<tbody>\n<tr>\n<td><code>bar</code></td>\n<td align=\"left\"><a href=\"/url\">baz</a></td>\n</tr>\n</tbody>\n</table>\n"},
+		// Ragged rows are padded or truncated.
+		{"| a | b |\n| - | - |\n| 1 |\n| 1 | 2 | 3 |\n|\n",
+			"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n<tbody>\n" +
+				"<tr>\n<td>1</td>\n<td></td>\n</tr>\n<tr>\n<td>1</td>\n<td>2</td>\n</tr>\n<tr>\n<td></td>\n<td></td>\n</tr>\n</tbody>\n</table>\n"},
+		// Escaped pipes, also in code spans.
+		{"| f\\|oo |\n| ------ |\n| b `\\|` az |\n| b **\\|** im |\n",
+			"<table>\n<thead>\n<tr>\n<th>f|oo</th>\n</tr>\n</thead>\n<tbody>\n" +
+				"<tr>\n<td>b <code>|</code> az</td>\n</tr>\n<tr>\n<td>b <strong>|</strong> im</td>\n</tr>\n</tbody>\n</table>\n"},
+		// A table without body rows.
+		{"| abc | def |\n| --- | --- |\n",
+			"<table>\n<thead>\n<tr>\n<th>abc</th>\n<th>def</th>\n</tr>\n</thead>\n</table>\n"},
+		// The table ends at a blank line or the start of another block.
+		{"| abc |\n| --- |\n| bar |\nbaz\n\nqux\n",
+			"<table>\n<thead>\n<tr>\n<th>abc</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>bar</td>\n</tr>\n<tr>\n<td>baz</td>\n</tr>\n</tbody>\n</table>\n<p>qux</p>\n"},
+		{"| abc |\n| --- |\n| bar |\n> bar\n",
+			"<table>\n<thead>\n<tr>\n<th>abc</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>bar</td>\n</tr>\n</tbody>\n</table>\n<blockquote>\n<p>bar</p>\n</blockquote>\n"},
+		// Earlier lines of the paragraph are not part of the table.
+		{"intro\n| a |\n| - |\n", "<p>intro</p>\n<table>\n<thead>\n<tr>\n<th>a</th>\n</tr>\n</thead>\n</table>\n"},
+		// The header row must match the delimiter row in the number of cells.
+		{"| abc | def |\n| --- |\n| bar |\n", "<p>| abc | def |\n| --- |\n| bar |</p>\n"},
+		// Without pipes or colons, the delimiter row is a setext underline.
+		{"abc\n---\n", "<h2>abc</h2>\n"},
+		// Tables can be nested in other blocks.
+		{"- | a |\n  | - |\n  | b |\n",
+			"<ul>\n<li><table>\n<thead>\n<tr>\n<th>a</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>b</td>\n</tr>\n</tbody>\n</table></li>\n</ul>\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"| a |\n| - |\n", "<p>| a |\n| - |</p>\n"},
+	})
+}
+
+func TestTaskLists(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{TaskLists: true}, []conversionTest{
+		{"- [ ] foo\n- [x] bar\n- [X] baz\n",
+			"<ul>\n<li><input type=\"checkbox\" disabled=\"\" /> foo</li>\n" +
+				"<li><input type=\"checkbox\" checked=\"\" disabled=\"\" /> bar</li>\n" +
+				"<li><input type=\"checkbox\" checked=\"\" disabled=\"\" /> baz</li>\n</ul>\n"},
+		// Mixed lists.
+		{"1. [x] foo\n2. bar\n3. [ ] *baz*\n",
+			"<ol>\n<li><input type=\"checkbox\" checked=\"\" disabled=\"\" /> foo</li>\n<li>bar</li>\n" +
+				"<li><input type=\"checkbox\" disabled=\"\" /> <em>baz</em></li>\n</ol>\n"},
+		// Loose lists and nested lists.
+		{"- [x] foo\n\n  bar\n- [ ] baz\n  - [ ] qux\n",
+			"<ul>\n<li><p><input type=\"checkbox\" checked=\"\" disabled=\"\" /> foo</p>\n<p>bar</p></li>\n" +
+				"<li><p><input type=\"checkbox\" disabled=\"\" /> baz</p>\n<ul>\n<li><input type=\"checkbox\" disabled=\"\" /> qux</li>\n</ul></li>\n</ul>\n"},
+		{"- [ ]\n  foo\n", "<ul>\n<li><input type=\"checkbox\" disabled=\"\" /> \nfoo</li>\n</ul>\n"},
+		// The marker must be at the start of the item, and be followed by
+		// whitespace.
+		{"- foo [ ] bar\n- *[x]* foo\n- [x]foo\n- [y] foo\n- \\[x] foo\n",
+			"<ul>\n<li>foo [ ] bar</li>\n<li><em>[x]</em> foo</li>\n<li>[x]foo</li>\n<li>[y] foo</li>\n<li>[x] foo</li>\n</ul>\n"},
+		{"- > [x] foo\n", "<ul>\n<li><blockquote>\n<p>[x] foo</p>\n</blockquote></li>\n</ul>\n"},
+		{"[x] foo\n", "<p>[x] foo</p>\n"},
+		// The marker takes precedence over a shortcut reference link.
+		{"- [x]\n\n[x]: /url\n", "<ul>\n<li><input type=\"checkbox\" checked=\"\" disabled=\"\" /> </li>\n</ul>\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"- [x] foo\n", "<ul>\n<li>[x] foo</li>\n</ul>\n"},
+	})
+}
+
+func TestAutolinkURLs(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{AutolinkURLs: true}, []conversionTest{
+		{"www.commonmark.org\n", "<p><a href=\"http://www.commonmark.org\">www.commonmark.org</a></p>\n"},
+		{"Visit https://commonmark.org/help for more.\n", "<p>Visit <a href=\"https://commonmark.org/help\">https://commonmark.org/help</a> for more.</p>\n"},
+		// Trailing punctuation is not part of the link.
+		{"Visit www.commonmark.org.\n", "<p>Visit <a href=\"http://www.commonmark.org\">www.commonmark.org</a>.</p>\n"},
+		{"Visit www.commonmark.org/a.b.\n", "<p>Visit <a href=\"http://www.commonmark.org/a.b\">www.commonmark.org/a.b</a>.</p>\n"},
+		{"Is it http://example.com/?!\n", "<p>Is it <a href=\"http://example.com/\">http://example.com/</a>?!</p>\n"},
+		// Parentheses.
+		{"(www.google.com/search?q=Markup+(business))\n",
+			"<p>(<a href=\"http://www.google.com/search?q=Markup+(business)\">www.google.com/search?q=Markup+(business)</a>)</p>\n"},
+		{"www.google.com/search?q=Markup+(business)))\n",
+			"<p><a href=\"http://www.google.com/search?q=Markup+(business)\">www.google.com/search?q=Markup+(business)</a>))</p>\n"},
+		{"www.google.com/search?q=(business))+ok\n",
+			"<p><a href=\"http://www.google.com/search?q=(business))+ok\">www.google.com/search?q=(business))+ok</a></p>\n"},
+		// Entities and <.
+		{"www.google.com/search?q=commonmark&hl=en\n",
+			"<p><a href=\"http://www.google.com/search?q=commonmark&amp;hl=en\">www.google.com/search?q=commonmark&amp;hl=en</a></p>\n"},
+		{"www.google.com/search?q=commonmark&hl;\n",
+			"<p><a href=\"http://www.google.com/search?q=commonmark\">www.google.com/search?q=commonmark</a>&amp;hl;</p>\n"},
+		{"www.commonmark.org/he<lp\n", "<p><a href=\"http://www.commonmark.org/he\">www.commonmark.org/he</a>&lt;lp</p>\n"},
+		// Domains.
+		{"https://example.com/foo_bar_baz\n", "<p><a href=\"https://example.com/foo_bar_baz\">https://example.com/foo_bar_baz</a></p>\n"},
+		{"http://localhost/ www.a_b.com https://a.b_c\n", "<p>http://localhost/ www.a_b.com https://a.b_c</p>\n"},
+		// Email addresses.
+		{"foo@bar.baz\n", "<p><a href=\"mailto:foo@bar.baz\">foo@bar.baz</a></p>\n"},
+		{"hello@mail+xyz.example isn't valid, but hello+xyz@mail.example is.\n",
+			"<p>hello@mail+xyz.example isn't valid, but <a href=\"mailto:hello+xyz@mail.example\">hello+xyz@mail.example</a> is.</p>\n"},
+		{"a.b-c_d@a.b.\n", "<p><a href=\"mailto:a.b-c_d@a.b\">a.b-c_d@a.b</a>.</p>\n"},
+		{"a.b-c_d@a.b- a.b-c_d@a.b_\n", "<p>a.b-c_d@a.b- a.b-c_d@a.b_</p>\n"},
+		// Autolinks must start at a word boundary.
+		{"xwww.a.com *www.a.com* ~www.a.com\n",
+			"<p>xwww.a.com <em><a href=\"http://www.a.com\">www.a.com</a></em> ~<a href=\"http://www.a.com\">www.a.com</a></p>\n"},
+		// Only text is affected.
+		{"`www.a.com` <www.a.com>\n", "<p><code>www.a.com</code> &lt;www.a.com&gt;</p>\n"},
+		{"[https://example.com](https://example.com) [www.a.com]\n",
+			"<p><a href=\"https://example.com\">https://example.com</a> [www.a.com]</p>\n"},
+		{"<http://example.com/?q=www.a.com>\n",
+			"<p><a href=\"http://example.com/?q=www.a.com\">http://example.com/?q=www.a.com</a></p>\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"www.commonmark.org foo@bar.baz\n", "<p>www.commonmark.org foo@bar.baz</p>\n"},
+	})
+}
+
+func TestFootnotes(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{Footnotes: true}, []conversionTest{
+		{"Foo[^1] bar.\n\n[^1]: The note.\n",
+			"<p>Foo<sup class=\"footnote-ref\"><a href=\"#fn-1\" id=\"fnref-1\">1</a></sup> bar.</p>\n" +
+				"<section class=\"footnotes\">\n<ol>\n<li id=\"fn-1\">\n" +
+				"<p>The note. <a href=\"#fnref-1\" class=\"footnote-backref\">↩</a></p>\n</li>\n</ol>\n</section>\n"},
+		// Multiple references each get a back reference.
+		{"a[^x] b[^x] c[^X]\n\n[^x]: Note\n",
+			"<p>a<sup class=\"footnote-ref\"><a href=\"#fn-x\" id=\"fnref-x\">1</a></sup>" +
+				" b<sup class=\"footnote-ref\"><a href=\"#fn-x\" id=\"fnref-x-2\">1</a></sup>" +
+				" c<sup class=\"footnote-ref\"><a href=\"#fn-x\" id=\"fnref-x-3\">1</a></sup></p>\n" +
+				"<section class=\"footnotes\">\n<ol>\n<li id=\"fn-x\">\n<p>Note" +
+				" <a href=\"#fnref-x\" class=\"footnote-backref\">↩</a>" +
+				" <a href=\"#fnref-x-2\" class=\"footnote-backref\">↩<sup>2</sup></a>" +
+				" <a href=\"#fnref-x-3\" class=\"footnote-backref\">↩<sup>3</sup></a></p>\n</li>\n</ol>\n</section>\n"},
+		// Footnotes are numbered in order of first reference, and unreferenced
+		// ones are dropped.
+		{"[^a]: A\n[^b]: B\n[^c]: C\n\nx[^c] y[^a] z[^c]\n",
+			"<p>x<sup class=\"footnote-ref\"><a href=\"#fn-c\" id=\"fnref-c\">1</a></sup>" +
+				" y<sup class=\"footnote-ref\"><a href=\"#fn-a\" id=\"fnref-a\">2</a></sup>" +
+				" z<sup class=\"footnote-ref\"><a href=\"#fn-c\" id=\"fnref-c-2\">1</a></sup></p>\n" +
+				"<section class=\"footnotes\">\n<ol>\n" +
+				"<li id=\"fn-c\">\n<p>C <a href=\"#fnref-c\" class=\"footnote-backref\">↩</a>" +
+				" <a href=\"#fnref-c-2\" class=\"footnote-backref\">↩<sup>2</sup></a></p>\n</li>\n" +
+				"<li id=\"fn-a\">\n<p>A <a href=\"#fnref-a\" class=\"footnote-backref\">↩</a></p>\n</li>\n" +
+				"</ol>\n</section>\n"},
+		// Undefined references are literal.
+		{"foo[^nope]\n", "<p>foo[^nope]</p>\n"},
+		{"[^1]: unused\n", ""},
+		// Definitions can contain multiple blocks, and the first definition
+		// wins.
+		{"x[^1]\n\n[^1]: *One*\n  lazy\n\n        code\n\n    > quote\n\n[^1]: Two\n",
+			"<p>x<sup class=\"footnote-ref\"><a href=\"#fn-1\" id=\"fnref-1\">1</a></sup></p>\n" +
+				"<section class=\"footnotes\">\n<ol>\n<li id=\"fn-1\">\n<p><em>One</em>\nlazy</p>\n" +
+				"<pre><code>code\n</code></pre>\n<blockquote>\n<p>quote</p>\n</blockquote>\n" +
+				"<p><a href=\"#fnref-1\" class=\"footnote-backref\">↩</a></p>\n</li>\n</ol>\n</section>\n"},
+		{"x[^1]\n\n[^1]: One\n\nNot part of it.\n",
+			"<p>x<sup class=\"footnote-ref\"><a href=\"#fn-1\" id=\"fnref-1\">1</a></sup></p>\n<p>Not part of it.</p>\n" +
+				"<section class=\"footnotes\">\n<ol>\n<li id=\"fn-1\">\n" +
+				"<p>One <a href=\"#fnref-1\" class=\"footnote-backref\">↩</a></p>\n</li>\n</ol>\n</section>\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"Foo[^1]\n\n[^1]: /url\n", "<p>Foo<a href=\"/url\">^1</a></p>\n"},
+	})
+}
+
+func TestTabWidth(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{TabWidth: 8}, []conversionTest{
+		{"\tfoo\n", "<pre><code>    foo\n</code></pre>\n"},
+		{"  \tfoo\n", "<pre><code>    foo\n</code></pre>\n"},
+		{"-\tfoo\n", "<ul>\n<li><pre><code>  foo\n</code></pre></li>\n</ul>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{TabWidth: 2}, []conversionTest{
+		{"\tfoo\n", "<p>foo</p>\n"},
+		{"\t\tfoo\n", "<pre><code>foo\n</code></pre>\n"},
+		{">\t\tfoo\n", "<blockquote>\n<p>foo</p>\n</blockquote>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{TabWidth: 4}, []conversionTest{
+		{"\tfoo\n", "<pre><code>foo\n</code></pre>\n"},
+	})
+}
+
+func TestStrict(t *testing.T) {
+	tests := []struct {
+		input     string
+		line, col int
+		msg       string
+	}{
+		{"foo\n\n```go\nbar\n", 3, 1, "code fence \"```\" is never closed"},
+		{"> ~~~~\n> code\n\nafter\n", 1, 3, "code fence \"~~~~\" is never closed"},
+		{"a\n\n  <!-- comment\n\nb\n", 3, 3, "HTML block is never ended"},
+		{"- <pre>\n  x\n- y\n", 1, 3, "HTML block is never ended"},
+		{"\t```\n\n```\nnope\n", 3, 1, "code fence \"```\" is never closed"},
+		// Only the first problem is reported.
+		{"<script>\n\n```\n", 1, 1, "HTML block is never ended"},
+	}
+	for _, test := range tests {
+		_, err := ToHTMLBytesWithOptions([]byte(test.input), Options{Strict: true})
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("ToHTMLBytesWithOptions(%q) returned error %v, want a *ParseError", test.input, err)
+			continue
+		}
+		if parseErr.Line != test.line || parseErr.Col != test.col || parseErr.Msg != test.msg {
+			t.Errorf("ToHTMLBytesWithOptions(%q) returned error at %d:%d: %q, want %d:%d: %q",
+				test.input, parseErr.Line, parseErr.Col, parseErr.Msg, test.line, test.col, test.msg)
+		}
+	}
+
+	// Closed blocks, and blocks that are ended by a blank line, are fine.
+	runConversionTestsWithOptions(t, Options{Strict: true}, []conversionTest{
+		{"```\ncode\n```\n", "<pre><code>code\n</code></pre>\n"},
+		{"<!-- a\nb -->\n", "<!-- a\nb -->\n"},
+		{"<div>\nfoo\n", "<div>\nfoo\n"},
+	})
+
+	_, err := ToHTMLBytesWithOptions([]byte("```\n"), Options{Strict: true})
+	if err == nil || err.Error() != "commonmark: line 1, column 1: code fence \"```\" is never closed" {
+		t.Errorf("unexpected error message: %v", err)
+	}
+}
+
+func TestHeadingIDs(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{HeadingIDs: true}, []conversionTest{
+		{"## My Heading\n", "<h2 id=\"my-heading\">My Heading</h2>\n"},
+		// Punctuation is dropped, but markup contributes its text.
+		{"# Hello, *World*! (`v1.2`)\n", "<h1 id=\"hello-world-v12\">Hello, <em>World</em>! (<code>v1.2</code>)</h1>\n"},
+		{"# [Link](/url \"title\") & <b>HTML</b>\n", "<h1 id=\"link--html\"><a href=\"/url\" title=\"title\">Link</a> &amp; <b>HTML</b></h1>\n"},
+		{"# snake_case and kebab-case\n", "<h1 id=\"snake_case-and-kebab-case\">snake_case and kebab-case</h1>\n"},
+		// Letters in any script are kept and lowercased.
+		{"# Ünïcödé Straße\n", "<h1 id=\"ünïcödé-straße\">Ünïcödé Straße</h1>\n"},
+		{"# Привет Мир 日本語\n", "<h1 id=\"привет-мир-日本語\">Привет Мир 日本語</h1>\n"},
+		// Duplicates get a numeric suffix, which does not clash with other
+		// headings.
+		{"# Foo\n## Foo\nFoo\n===\n", "<h1 id=\"foo\">Foo</h1>\n<h2 id=\"foo-1\">Foo</h2>\n<h1 id=\"foo-2\">Foo</h1>\n"},
+		{"# Foo 1\n# Foo\n# Foo\n", "<h1 id=\"foo-1\">Foo 1</h1>\n<h1 id=\"foo\">Foo</h1>\n<h1 id=\"foo-2\">Foo</h1>\n"},
+		// Headings without letters or digits get no id.
+		{"# ?!\n#\n", "<h1>?!</h1>\n<h1></h1>\n"},
+		{"> # Quoted\n", "<blockquote>\n<h1 id=\"quoted\">Quoted</h1>\n</blockquote>\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"## My Heading\n", "<h2>My Heading</h2>\n"},
+	})
+}
+
+func TestSlugify(t *testing.T) {
+	transliterate := strings.NewReplacer("è", "e", "û", "u", "é", "e", " ", "_")
+	slugify := func(text string) string {
+		if text == "Skip" {
+			return ""
+		}
+		return "s-" + transliterate.Replace(strings.ToLower(text))
+	}
+	runConversionTestsWithOptions(t, Options{HeadingIDs: true, Slugify: slugify}, []conversionTest{
+		{"# Crème *Brûlée*\n", "<h1 id=\"s-creme_brulee\">Crème <em>Brûlée</em></h1>\n"},
+		{"#   Foo  \n# Foo\nFoo\n---\n", "<h1 id=\"s-foo\">Foo</h1>\n<h1 id=\"s-foo-1\">Foo</h1>\n<h2 id=\"s-foo-2\">Foo</h2>\n"},
+		{"# Skip\n# ?!\n", "<h1>Skip</h1>\n<h1 id=\"s-?!\">?!</h1>\n"},
+	})
+}
+
+func TestBaseURL(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{BaseURL: "https://example.com/docs/"}, []conversionTest{
+		// Relative destinations are resolved.
+		{"[a](foo/bar)\n", "<p><a href=\"https://example.com/docs/foo/bar\">a</a></p>\n"},
+		{"[a](/img.png)\n", "<p><a href=\"https://example.com/img.png\">a</a></p>\n"},
+		{"[a](../up?q=1#x)\n", "<p><a href=\"https://example.com/up?q=1#x\">a</a></p>\n"},
+		{"![a](img.png \"t\")\n", "<p><img src=\"https://example.com/docs/img.png\" alt=\"a\" title=\"t\" /></p>\n"},
+		{"[a]\n\n[a]: x%20y\n", "<p><a href=\"https://example.com/docs/x%20y\">a</a></p>\n"},
+		{"[a](<f ö>)\n", "<p><a href=\"https://example.com/docs/f%20%C3%B6\">a</a></p>\n"},
+		// Absolute URLs, network-path references and fragments are left
+		// alone.
+		{"[a](http://other.org/x)\n", "<p><a href=\"http://other.org/x\">a</a></p>\n"},
+		{"<mailto:a@b.c>\n", "<p><a href=\"mailto:a@b.c\">mailto:a@b.c</a></p>\n"},
+		{"![a](//cdn/x)\n", "<p><img src=\"//cdn/x\" alt=\"a\" /></p>\n"},
+		{"[a](#fragment)\n", "<p><a href=\"#fragment\">a</a></p>\n"},
+		{"[a]()\n", "<p><a href=\"\">a</a></p>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{BaseURL: "/sub/page"}, []conversionTest{
+		{"[a](other)\n", "<p><a href=\"/sub/other\">a</a></p>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{BaseURL: "http://[invalid"}, []conversionTest{
+		{"[a](foo)\n", "<p><a href=\"foo\">a</a></p>\n"},
+	})
+}
+
+func TestLineEnding(t *testing.T) {
+	input := "# a\r\nb\nc\r\n\r\n    d\r    e\n\n<div>\r\nf\n"
+	runConversionTestsWithOptions(t, Options{LineEnding: LineEndingLF}, []conversionTest{
+		{input, "<h1>a</h1>\n<p>b\nc</p>\n<pre><code>d\ne\n</code></pre>\n<div>\nf\n"},
+	})
+	runConversionTestsWithOptions(t, Options{LineEnding: LineEndingCRLF}, []conversionTest{
+		{input, "<h1>a</h1>\r\n<p>b\r\nc</p>\r\n<pre><code>d\r\ne\r\n</code></pre>\r\n<div>\r\nf\r\n"},
+		{"- a\n- b\n", "<ul>\r\n<li>a</li>\r\n<li>b</li>\r\n</ul>\r\n"},
+	})
+	runConversionTestsWithOptions(t, Options{LineEnding: LineEndingPreserve}, []conversionTest{
+		{input, "<h1>a</h1>\r\n<p>b\r\nc</p>\r\n<pre><code>d\r\ne\r\n</code></pre>\r\n<div>\r\nf\r\n"},
+		{"a\rb\r\nc\n", "<p>a\rb\rc</p>\r"},
+		{"a\nb\r\n", "<p>a\nb</p>\n"},
+		// Code blocks are no exception, and their final line ending is kept.
+		{"```\r\na\r\n\r\nb\r\n```\r\n", "<pre><code>a\r\n\r\nb\r\n</code></pre>\r\n"},
+		{"```\r\na\r\nb", "<pre><code>a\r\nb\r\n</code></pre>\r\n"},
+		{"a", "<p>a</p>\n"},
+		{"", ""},
+	})
+
+	// The line ending is remembered when rendering a parsed document.
+	doc, err := ParseDocumentWithOptions([]byte("a\r\nb\n"), Options{LineEnding: LineEndingPreserve})
+	if err != nil {
+		t.Fatalf("ParseDocumentWithOptions returned error: %s", err)
+	}
+	var output bytes.Buffer
+	n, err := doc.WriteTo(&output)
+	if err != nil {
+		t.Errorf("WriteTo returned error: %s", err)
+	}
+	if expected := "<p>a\r\nb</p>\r\n"; output.String() != expected {
+		t.Errorf("WriteTo wrote %q, want %q", output.String(), expected)
+	}
+	if n != int64(output.Len()) {
+		t.Errorf("WriteTo returned %d, but wrote %d bytes", n, output.Len())
+	}
+}
+
+func TestMaxNestingDepth(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{MaxNestingDepth: 2}, []conversionTest{
+		{"> > > a\n", "<blockquote>\n<blockquote>\n<p>&gt; a</p>\n</blockquote>\n</blockquote>\n"},
+		{"> > > a\n> > b\n", "<blockquote>\n<blockquote>\n<p>&gt; a\nb</p>\n</blockquote>\n</blockquote>\n"},
+		{"- > - a\n", "<ul>\n<li>&gt; - a</li>\n</ul>\n"},
+		{"> # a\n", "<blockquote>\n<h1>a</h1>\n</blockquote>\n"},
+		// The third opening bracket is text, so the first closing bracket
+		// matches the second opening bracket.
+		{"![![![a](b)](c)](d)\n", "<p><img src=\"c\" alt=\"![a\" />](d)</p>\n"},
+		{"[a [b [c](d)\n", "<p>[a <a href=\"d\">b [c</a></p>\n"},
+	})
+
+	// Deeply nested input is handled without problems by default.
+	depth := 100000
+	input := strings.Repeat("> ", depth) + "a\n"
+	output, err := ToHTMLBytes([]byte(input))
+	if err != nil {
+		t.Fatalf("ToHTMLBytes returned error: %s", err)
+	}
+	if n := strings.Count(string(output), "<blockquote>"); n != 1000 {
+		t.Errorf("%d nested block quotes were rendered, want 1000", n)
+	}
+	input = strings.Repeat("![", depth) + "a" + strings.Repeat("](b)", depth) + "\n"
+	output, err = ToHTMLBytes([]byte(input))
+	if err != nil {
+		t.Fatalf("ToHTMLBytes returned error: %s", err)
+	}
+	if n := strings.Count(string(output), "<img"); n != 1 {
+		t.Errorf("%d images were rendered, want 1", n)
+	}
+}
+
+func TestDefinitionLists(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{DefinitionLists: true}, []conversionTest{
+		{"Apple\n: A *fruit*.\n", "<dl>\n<dt>Apple</dt>\n<dd>A <em>fruit</em>.</dd>\n</dl>\n"},
+		// Multiple terms and definitions, and multiple groups in a list.
+		{"Term 1\nTerm 2\n: Def a\n: Def b\n\nTerm 3\n:   Def c\n    lazy\n",
+			"<dl>\n<dt>Term 1</dt>\n<dt>Term 2</dt>\n<dd>Def a</dd>\n<dd>Def b</dd>\n<dt>Term 3</dt>\n<dd>Def c\nlazy</dd>\n</dl>\n"},
+		// A blank line before a definition, or between its blocks, wraps its
+		// paragraphs in <p> tags.
+		{"Term\n\n: Def a\n\n  Second paragraph\n\n  - x\n  - y\n: Def b\n\nAfter\n",
+			"<dl>\n<dt>Term</dt>\n<dd><p>Def a</p>\n<p>Second paragraph</p>\n<ul>\n<li>x</li>\n<li>y</li>\n</ul></dd>\n<dd>Def b</dd>\n</dl>\n<p>After</p>\n"},
+		{"Term\n: Def a\n\n: Def b\n", "<dl>\n<dt>Term</dt>\n<dd>Def a</dd>\n<dd><p>Def b</p></dd>\n</dl>\n"},
+		{"Term\n:     code\n", "<dl>\n<dt>Term</dt>\n<dd><pre><code>code\n</code></pre></dd>\n</dl>\n"},
+		{"- Term\n  : Def\n- Other\n", "<ul>\n<li><dl>\n<dt>Term</dt>\n<dd>Def</dd>\n</dl></li>\n<li>Other</li>\n</ul>\n"},
+		// Link reference definitions are not terms.
+		{"[a]: /url\nTerm [a]\n: Def\n", "<dl>\n<dt>Term <a href=\"/url\">a</a></dt>\n<dd>Def</dd>\n</dl>\n"},
+		// Colons that do not start definitions.
+		{": Nothing to define\n", "<p>: Nothing to define</p>\n"},
+		{"foo\n:bar\n", "<p>foo\n:bar</p>\n"},
+		{"> Term\n: lazy\n", "<blockquote>\n<p>Term\n: lazy</p>\n</blockquote>\n"},
+		{"Term\n\n    : code\n", "<p>Term</p>\n<pre><code>: code\n</code></pre>\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"Apple\n: A fruit.\n", "<p>Apple\n: A fruit.</p>\n"},
+	})
+}
+
+func TestFencedCodeAttributes(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{FencedCodeAttributes: true}, []conversionTest{
+		{"```{.go .numberLines}\nx\n```\n", "<pre><code class=\"go numberLines\">x\n</code></pre>\n"},
+		{"``` { #main .go startFrom=\"10 0\" }\nx\n```\n", "<pre><code id=\"main\" class=\"go\">x\n</code></pre>\n"},
+		{"~~~{#a #b}\nx\n~~~\n", "<pre><code id=\"b\">x\n</code></pre>\n"},
+		{"```{}\nx\n```\n", "<pre><code>x\n</code></pre>\n"},
+		{"```{.a\"&<}\nx\n```\n", "<pre><code class=\"a&quot;&amp;&lt;\">x\n</code></pre>\n"},
+		// Without braces, the first word is the language.
+		{"```go\nx\n```\n", "<pre><code class=\"language-go\">x\n</code></pre>\n"},
+		{"```go {.numberLines}\nx\n```\n", "<pre><code class=\"language-go\">x\n</code></pre>\n"},
+		{"```{.go\nx\n```\n", "<pre><code class=\"language-{.go\">x\n</code></pre>\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"```{.go .numberLines}\nx\n```\n", "<pre><code class=\"language-{.go\">x\n</code></pre>\n"},
+	})
+}
+
+func TestCodeBlockLanguagePrefix(t *testing.T) {
+	tests := []struct {
+		options Options
+		output  string
+	}{
+		{Options{}, "<pre><code class=\"language-go\">x\n</code></pre>\n"},
+		{Options{CodeBlockLanguagePrefix: "lang-"}, "<pre><code class=\"lang-go\">x\n</code></pre>\n"},
+		{Options{CodeBlockLanguagePrefix: "highlight "}, "<pre><code class=\"highlight go\">x\n</code></pre>\n"},
+		{Options{CodeBlockLanguagePrefix: "\"&"}, "<pre><code class=\"&quot;&amp;go\">x\n</code></pre>\n"},
+		// An empty prefix must be asked for explicitly, because it is the
+		// zero value.
+		{Options{NoCodeBlockLanguagePrefix: true}, "<pre><code class=\"go\">x\n</code></pre>\n"},
+		{Options{CodeBlockLanguagePrefix: " "}, "<pre><code class=\" go\">x\n</code></pre>\n"},
+	}
+	for _, test := range tests {
+		runConversionTestsWithOptions(t, test.options, []conversionTest{
+			{"```go\nx\n```\n", test.output},
+			{"```\nx\n```\n", "<pre><code>x\n</code></pre>\n"},
+			{"    x\n", "<pre><code>x\n</code></pre>\n"},
+		})
+	}
+	runConversionTestsWithOptions(t, Options{CodeBlockLanguagePrefix: "lang-", FencedCodeAttributes: true}, []conversionTest{
+		{"```{.go}\nx\n```\n", "<pre><code class=\"go\">x\n</code></pre>\n"},
+	})
+}
+
+func TestWrapper(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{WrapperTag: "div", WrapperClass: "markdown-body"}, []conversionTest{
+		{"# foo\n\nbar\n\n- baz\n", "<div class=\"markdown-body\">\n<h1>foo</h1>\n<p>bar</p>\n<ul>\n<li>baz</li>\n</ul>\n</div>\n"},
+		{"", "<div class=\"markdown-body\">\n</div>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{WrapperTag: "article"}, []conversionTest{
+		{"foo\n", "<article>\n<p>foo</p>\n</article>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{WrapperClass: "a\"b"}, []conversionTest{
+		{"foo\n", "<div class=\"a&quot;b\">\n<p>foo</p>\n</div>\n"},
+	})
+	// Invalid tag names cannot inject markup.
+	for _, tag := range []string{"div onclick=x", "div><script>", "1div", "-", "déjà"} {
+		runConversionTestsWithOptions(t, Options{WrapperTag: tag}, []conversionTest{
+			{"foo\n", "<div>\n<p>foo</p>\n</div>\n"},
+		})
+	}
+	runConversionTestsWithOptions(t, Options{WrapperTag: "my-Element2"}, []conversionTest{
+		{"foo\n", "<my-Element2>\n<p>foo</p>\n</my-Element2>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{WrapperTag: "div", LineEnding: LineEndingCRLF}, []conversionTest{
+		{"foo\n", "<div>\r\n<p>foo</p>\r\n</div>\r\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"# foo\n\nbar\n", "<h1>foo</h1>\n<p>bar</p>\n"},
+	})
+}
+
+func TestHTML5(t *testing.T) {
+	tests := []struct {
+		input, xhtml, html5 string
+	}{
+		{"***\n", "<hr />\n", "<hr>\n"},
+		{"foo\\\nbar\n", "<p>foo<br />\nbar</p>\n", "<p>foo<br>\nbar</p>\n"},
+		{"![foo](/url \"title\")\n", "<p><img src=\"/url\" alt=\"foo\" title=\"title\" /></p>\n", "<p><img src=\"/url\" alt=\"foo\" title=\"title\"></p>\n"},
+		{"<br/>\n", "<br/>\n", "<br/>\n"},
+	}
+	for _, test := range tests {
+		runConversionTests(t, []conversionTest{{test.input, test.xhtml}})
+		runConversionTestsWithOptions(t, Options{HTML5: true}, []conversionTest{{test.input, test.html5}})
+	}
+	runConversionTestsWithOptions(t, Options{HTML5: true, HardWraps: true, TaskLists: true}, []conversionTest{
+		{"- [x] foo\n  bar\n", "<ul>\n<li><input type=\"checkbox\" checked=\"\" disabled=\"\"> foo<br>\nbar</li>\n</ul>\n"},
+	})
+}
+
+func TestDisabledInlines(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{DisabledInlines: Images | RawHTML}, []conversionTest{
+		{"![x](y)\n", "<p>![x](y)</p>\n"},
+		{"![x][y]\n\n[y]: /url\n", "<p>![x]<a href=\"/url\">y</a></p>\n"},
+		{"[a](b)\n", "<p><a href=\"b\">a</a></p>\n"},
+		// The literal ![ does not open a bracket of its own.
+		{"[![x](y)](z)\n", "<p><a href=\"y\">![x</a>](z)</p>\n"},
+		{"*a* <b>c</b>\n", "<p><em>a</em> &lt;b&gt;c&lt;/b&gt;</p>\n"},
+		{"<http://a.b>\n", "<p><a href=\"http://a.b\">http://a.b</a></p>\n"},
+		{"<div>\n", "<div>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{DisabledInlines: CodeSpans | Emphasis | Links | Autolinks}, []conversionTest{
+		{"`a` *b* __c__\n", "<p>`a` *b* __c__</p>\n"},
+		{"[a](b) [c]\n\n[c]: /url\n", "<p>[a](b) [c]</p>\n"},
+		{"![x](y)\n", "<p><img src=\"y\" alt=\"x\" /></p>\n"},
+		{"<http://a.b> <i>\n", "<p>&lt;http://a.b&gt; <i></p>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{DisabledInlines: Links, Footnotes: true}, []conversionTest{
+		{"a[^1]\n\n[^1]: b\n", "<p>a[^1]</p>\n"},
+	})
+}
+
+func TestTagFilter(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{TagFilter: true}, []conversionTest{
+		{"<script>alert(1)</script>\n", "&lt;script>alert(1)&lt;/script>\n"},
+		{"a <span>b</span> <SCRIPT src=\"x\"/>\n", "<p>a <span>b</span> &lt;SCRIPT src=\"x\"/></p>\n"},
+		{"<div>\n<style>p{}</style><xmp>\n</div>\n", "<div>\n&lt;style>p{}&lt;/style>&lt;xmp>\n</div>\n"},
+		{"a <iframe/> <title\nx=1> <textarea/>\n", "<p>a &lt;iframe/> &lt;title\nx=1> &lt;textarea/></p>\n"},
+		{"<noembed> <noframes> <plaintext>\n", "<p>&lt;noembed> &lt;noframes> &lt;plaintext></p>\n"},
+		// Only the exact tag names are filtered.
+		{"<scripts> <styles/> <title-x>\n", "<p><scripts> <styles/> <title-x></p>\n"},
+		// Text and code are escaped anyway.
+		{"`<script>`\n", "<p><code>&lt;script&gt;</code></p>\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"<script>alert(1)</script>\n", "<script>alert(1)</script>\n"},
+	})
+}
+
+func TestNofollowExternal(t *testing.T) {
+	options := Options{NofollowExternal: true, InternalHost: "example.com"}
+	runConversionTestsWithOptions(t, options, []conversionTest{
+		{"[a](http://other.org/x)\n", "<p><a href=\"http://other.org/x\" rel=\"nofollow noopener\">a</a></p>\n"},
+		{"[a](//other.org/x \"t\")\n", "<p><a href=\"//other.org/x\" title=\"t\" rel=\"nofollow noopener\">a</a></p>\n"},
+		{"<https://sub.example.com>\n", "<p><a href=\"https://sub.example.com\" rel=\"nofollow noopener\">https://sub.example.com</a></p>\n"},
+		{"[a](https://Example.COM:8080/x)\n", "<p><a href=\"https://Example.COM:8080/x\">a</a></p>\n"},
+		{"[a](/x) [b](x) [c](#x) [d](mailto:a@other.org)\n", "<p><a href=\"/x\">a</a> <a href=\"x\">b</a> <a href=\"#x\">c</a> <a href=\"mailto:a@other.org\">d</a></p>\n"},
+		{"![a](http://other.org/x.png)\n", "<p><img src=\"http://other.org/x.png\" alt=\"a\" /></p>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{NofollowExternal: true, InternalHost: "example.com", BaseURL: "http://other.org/"}, []conversionTest{
+		{"[a](x)\n", "<p><a href=\"http://other.org/x\">a</a></p>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{InternalHost: "example.com"}, []conversionTest{
+		{"[a](http://other.org/x)\n", "<p><a href=\"http://other.org/x\">a</a></p>\n"},
+	})
+}
+
+func TestExternalLinksNewWindow(t *testing.T) {
+	input := "[a](http://other.org/) [b](http://example.com/) [c](/c) <http://other.org>\n"
+	runConversionTestsWithOptions(t, Options{ExternalLinksNewWindow: true, InternalHost: "example.com"}, []conversionTest{
+		{input, "<p><a href=\"http://other.org/\" rel=\"noopener\" target=\"_blank\">a</a> " +
+			"<a href=\"http://example.com/\">b</a> <a href=\"/c\">c</a> " +
+			"<a href=\"http://other.org\" rel=\"noopener\" target=\"_blank\">http://other.org</a></p>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{ExternalLinksNewWindow: true, NofollowExternal: true, InternalHost: "example.com"}, []conversionTest{
+		{input, "<p><a href=\"http://other.org/\" rel=\"nofollow noopener\" target=\"_blank\">a</a> " +
+			"<a href=\"http://example.com/\">b</a> <a href=\"/c\">c</a> " +
+			"<a href=\"http://other.org\" rel=\"nofollow noopener\" target=\"_blank\">http://other.org</a></p>\n"},
+	})
+}
+
+func TestMath(t *testing.T) {
+	runConversionTestsWithOptions(t, Options{Math: true}, []conversionTest{
+		{"$a^2$\n", "<p><span class=\"math inline\">\\(a^2\\)</span></p>\n"},
+		{"Let $x < y$ and $$\\sum_i x_i$$.\n", "<p>Let <span class=\"math inline\">\\(x &lt; y\\)</span> and <span class=\"math display\">\\[\\sum_i x_i\\]</span>.</p>\n"},
+		{"$$\na *b* c\n$$\n", "<p><span class=\"math display\">\\[\na *b* c\n\\]</span></p>\n"},
+		{"$a\\$b$\n", "<p><span class=\"math inline\">\\(a\\$b\\)</span></p>\n"},
+		{"*$a*b$*\n", "<p><em><span class=\"math inline\">\\(a*b\\)</span></em></p>\n"},
+		{"$\\$$\n", "<p><span class=\"math inline\">\\(\\$\\)</span></p>\n"},
+		{"$$x$$ $$ x$$\n", "<p><span class=\"math display\">\\[x\\]</span> <span class=\"math display\">\\[ x\\]</span></p>\n"},
+		// Amounts of money are not math.
+		{"$5 and $10\n", "<p>$5 and $10</p>\n"},
+		{"from $20,000 to $30,000\n", "<p>from $20,000 to $30,000</p>\n"},
+		{"$ a$ $a $ $a$1\n", "<p>$ a$ $a $ $a$1</p>\n"},
+		{"Pay $5 or $10, where $x$ is the rate.\n", "<p>Pay $5 or $10, where <span class=\"math inline\">\\(x\\)</span> is the rate.</p>\n"},
+		{"It costs $5 and $x$ here.\n", "<p>It costs $5 and <span class=\"math inline\">\\(x\\)</span> here.</p>\n"},
+		{"$$ $$ $$5\n", "<p>$$ $$ $$5</p>\n"},
+		{"\\$a$\n", "<p>$a$</p>\n"},
+		{"`$a$`\n", "<p><code>$a$</code></p>\n"},
+	})
+	runConversionTests(t, []conversionTest{
+		{"$a^2$\n", "<p>$a^2$</p>\n"},
+	})
+}
+
+func TestReferenceDefinitions(t *testing.T) {
+	options := Options{ReferenceDefinitions: map[string]Reference{
+		"CommonMark":    {Destination: "http://commonmark.org", Title: "CommonMark"},
+		"foo  bar":      {Destination: "/foo bar"},
+		"local":         {Destination: "/external"},
+		"  ":            {Destination: "/invalid"},
+		"not used":      {Destination: "/unused"},
+		"&amp; \\* <b>": {Destination: "/raw&amp;"},
+	}}
+	runConversionTestsWithOptions(t, options, []conversionTest{
+		{"[commonmark]\n", "<p><a href=\"http://commonmark.org\" title=\"CommonMark\">commonmark</a></p>\n"},
+		{"[x][Foo\nBar] ![FOO BAR][]\n", "<p><a href=\"/foo%20bar\">x</a> <img src=\"/foo%20bar\" alt=\"FOO BAR\" /></p>\n"},
+		{"[local]\n\n[local]: /local\n", "<p><a href=\"/local\">local</a></p>\n"},
+		{"[local]\n", "<p><a href=\"/external\">local</a></p>\n"},
+		{"[&amp; \\* <b>]\n", "<p><a href=\"/raw&amp;amp;\">&amp; * <b></a></p>\n"},
+		{"[ ]\n", "<p>[ ]</p>\n"},
+		{"[other]\n", "<p>[other]</p>\n"},
+	})
+	// Parsing does not modify the definitions.
+	if len(options.ReferenceDefinitions) != 6 {
+		t.Errorf("ReferenceDefinitions has %d entries after parsing, expected 6", len(options.ReferenceDefinitions))
+	}
+}
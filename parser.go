@@ -0,0 +1,69 @@
+package commonmark
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Parser converts CommonMark to HTML with a fixed set of options. Any setup
+// that depends on the options is done once, by NewParser, instead of on every
+// conversion.
+//
+// A Parser is safe for concurrent use by multiple goroutines: its methods do
+// not modify it, and each conversion uses state of its own.
+type Parser struct {
+	options  Options
+	renderer Renderer
+}
+
+// NewParser returns a Parser that uses the given options. The zero value of
+// Options gives the same results as ToHTMLBytes.
+func NewParser(options Options) *Parser {
+	return &Parser{
+		options:  options,
+		renderer: NewHTMLRenderer(options),
+	}
+}
+
+// ToHTMLBytes converts text formatted in CommonMark into the corresponding
+// HTML, like ToHTMLBytesWithOptions with the options of the parser.
+func (p *Parser) ToHTMLBytes(data []byte) ([]byte, error) {
+	return p.AppendHTML(nil, data)
+}
+
+// AppendHTML is like ToHTMLBytes, but appends the HTML to dst and returns the
+// extended slice. As with the package-level AppendHTML, the HTML is copied
+// into dst once from an internal buffer. If an error occurs, dst is returned
+// unchanged.
+func (p *Parser) AppendHTML(dst, data []byte) ([]byte, error) {
+	doc, err := parse(bytes.NewReader(data), data, &p.options)
+	if err != nil {
+		return dst, err
+	}
+	buffer := outputBuffers.Get().(*bytes.Buffer)
+	defer putOutputBuffer(buffer)
+	if err := Render(buffer, documentToNode(doc), p.renderer); err != nil {
+		return dst, err
+	}
+	return append(dst, buffer.Bytes()...), nil
+}
+
+// outputBuffers holds buffers for the output of AppendHTML, which is copied
+// out, so that the buffer does not have to grow from scratch every time.
+var outputBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// maxPooledBufferSize is the capacity above which an output buffer is not
+// returned to the pool, so that a single huge document does not keep its
+// memory in use indefinitely.
+const maxPooledBufferSize = 1 << 16
+
+// putOutputBuffer returns the buffer to the pool, if it is not too large.
+func putOutputBuffer(buffer *bytes.Buffer) {
+	if buffer.Cap() > maxPooledBufferSize {
+		return
+	}
+	buffer.Reset()
+	outputBuffers.Put(buffer)
+}
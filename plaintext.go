@@ -0,0 +1,102 @@
+package commonmark
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// plainTextRenderer is the Renderer that produces plain text.
+type plainTextRenderer struct {
+	// wrote is true once any text has been written, so that the next block
+	// must be separated from it.
+	wrote bool
+}
+
+// NewPlainTextRenderer returns a Renderer that produces plain text, with all
+// markup removed: links are replaced by their text, images by their
+// description, and raw HTML and horizontal rules are left out. Paragraphs,
+// headings and code blocks are separated by blank lines, except for the items
+// of tight lists, which are on consecutive lines. Code blocks are written
+// verbatim. Table cells are separated by tabs, and table rows by newlines.
+//
+// The renderer keeps track of what it has written, so a new one should be
+// used for each tree that is not rooted at a Document node.
+func NewPlainTextRenderer() Renderer {
+	return &plainTextRenderer{}
+}
+
+// ToPlainText converts text formatted in CommonMark into plain text, as
+// produced by NewPlainTextRenderer. See ToHTMLBytes for details on the input.
+func ToPlainText(markdown []byte) ([]byte, error) {
+	root, err := ParseDocument(markdown)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	if err := Render(&buffer, root, NewPlainTextRenderer()); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (r *plainTextRenderer) RenderNode(out io.Writer, n *Node, entering bool) (WalkStatus, error) {
+	switch n.Type {
+	case Document:
+		r.wrote = false
+	case Paragraph, Heading, TableCell, DefinitionTerm:
+		if entering {
+			r.startBlock(n, out)
+		} else if n.Type != TableCell {
+			io.WriteString(out, "\n")
+		} else if n.next == nil {
+			// The newline ends the row.
+			io.WriteString(out, "\n")
+		}
+	case CodeBlock:
+		if entering {
+			r.startBlock(n, out)
+			out.Write(n.literal)
+		}
+	case HTMLBlock, HTMLInline, HorizontalRule:
+	case Text, Code, Math:
+		if entering {
+			out.Write(n.literal)
+		}
+	case SoftBreak, HardBreak:
+		if entering {
+			io.WriteString(out, "\n")
+		}
+	case FootnoteReference:
+		if entering {
+			fmt.Fprintf(out, "[%d]", n.footnoteNumber)
+		}
+	}
+	// The children of all other nodes, such as emphasis and links, are
+	// written as they are.
+	return WalkContinue, nil
+}
+
+// startBlock writes what separates the given block from the text written
+// before it, if any.
+func (r *plainTextRenderer) startBlock(n *Node, out io.Writer) {
+	if !r.wrote {
+		r.wrote = true
+		return
+	}
+	// Find the node that separates this block from the previous one.
+	m := n
+	for m.prev == nil && m.parent != nil {
+		m = m.parent
+	}
+	switch {
+	case m.Type == TableCell:
+		io.WriteString(out, "\t")
+	case m.Type == TableRow, m.Type == DefinitionTerm, m.Type == DefinitionDescription && m.tight:
+	case m.Type == Item && m.parent.tight:
+	case m.parent != nil && m.parent.Type == Item && m.parent.parent.tight:
+		// A block after the first block in an item of a tight list.
+	default:
+		io.WriteString(out, "\n")
+	}
+}
@@ -0,0 +1,51 @@
+package commonmark
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestToPlainText(t *testing.T) {
+	tests := []conversionTest{
+		{"", ""},
+		// Emphasis and other inline markup are removed.
+		{"Some *emphasized*, __strong__ and `code` text.\n", "Some emphasized, strong and code text.\n"},
+		{"A [link](/url \"title\"), <http://auto.link>, and ![an *image*](/img.png).\n",
+			"A link, http://auto.link, and an image.\n"},
+		{"&copy; <b>raw</b> HTML\\\nand a line break\n", "© raw HTML\nand a line break\n"},
+		// Blocks are separated by blank lines.
+		{"# Title\n\nFirst\nparagraph.\n\n> Quoted.\n\n***\n\nLast.\n", "Title\n\nFirst\nparagraph.\n\nQuoted.\n\nLast.\n"},
+		{"<div>\nHTML\n</div>\n\nText\n", "Text\n"},
+		// Code blocks are verbatim.
+		{"Code:\n\n    if a < b {\n\n        c()\n\n```\n*not emphasis*\n```\n", "Code:\n\nif a < b {\n\n    c()\n\n*not emphasis*\n"},
+		// Items of tight lists are on consecutive lines.
+		{"- one\n- two\n  - three\n\n1. four\n", "one\ntwo\nthree\n\nfour\n"},
+		{"- one\n\n- two\n  more\n", "one\n\ntwo\nmore\n"},
+	}
+	for _, test := range tests {
+		actual, err := ToPlainText([]byte(test.input))
+		if err != nil {
+			t.Errorf("error converting %q: %s", test.input, err)
+		} else if string(actual) != test.output {
+			t.Errorf("incorrect output for %q\nexpected:\n%q\nactual:\n%q", test.input, test.output, actual)
+		}
+	}
+}
+
+func TestPlainTextRendererExtensions(t *testing.T) {
+	input := "Term[^1]\n: ~~Definition~~\n\n| a | b |\n| - | - |\n| c | *d* |\n\n- [x] done\n\n[^1]: Note.\n"
+	expected := "Term[1]\nDefinition\n\na\tb\nc\td\n\ndone\n\nNote.\n"
+	root, err := ParseDocumentWithOptions([]byte(input), Options{
+		DefinitionLists: true, Footnotes: true, Strikethrough: true, Tables: true, TaskLists: true,
+	})
+	if err != nil {
+		t.Fatalf("ParseDocumentWithOptions returned error: %s", err)
+	}
+	var output bytes.Buffer
+	if err := Render(&output, root, NewPlainTextRenderer()); err != nil {
+		t.Fatalf("Render returned error: %s", err)
+	}
+	if output.String() != expected {
+		t.Errorf("incorrect output\nexpected:\n%q\nactual:\n%q", expected, output.String())
+	}
+}
@@ -0,0 +1,124 @@
+package commonmark
+
+import (
+	"unicode/utf8"
+)
+
+// Position is a location in the input.
+type Position struct {
+	// Line is the 1-based line number.
+	Line int `json:"line"`
+	// Column is the 1-based column, counted in bytes from the start of the
+	// line. Tabs count as a single byte. A byte order mark at the start of
+	// the input does not count.
+	Column int `json:"column"`
+	// Offset is the 0-based byte offset from the start of the input.
+	Offset int `json:"offset"`
+}
+
+// sourceMap maps locations in the lines as seen by the parser, which have had
+// their line endings normalized, their tabs expanded and their NUL characters
+// replaced, back to positions in the input.
+type sourceMap struct {
+	// lineOffsets holds the offset in the input of the start of each line.
+	lineOffsets []int
+	// lineLengths holds the length of each line in the input, excluding the
+	// line ending.
+	lineLengths []int
+	// changedLines holds the lines of the input that contained tabs or NUL
+	// characters, by line number. Other lines are the same as seen by the
+	// parser.
+	changedLines map[int][]byte
+	// tabStop is the tab stop with which the tabs were expanded.
+	tabStop int
+}
+
+// addLine records the next line of the input, which starts at the given
+// offset.
+func (m *sourceMap) addLine(line []byte, offset int, changed bool) {
+	m.lineOffsets = append(m.lineOffsets, offset)
+	m.lineLengths = append(m.lineLengths, len(line))
+	if changed {
+		if m.changedLines == nil {
+			m.changedLines = make(map[int][]byte)
+		}
+		m.changedLines[len(m.lineOffsets)] = append([]byte(nil), line...)
+	}
+}
+
+// position returns the position in the input of the given 0-based column in
+// the given line, as seen by the parser.
+func (m *sourceMap) position(line, column int) Position {
+	if line < 1 || line > len(m.lineOffsets) {
+		return Position{}
+	}
+	if changed, ok := m.changedLines[line]; ok {
+		column = unexpandedColumn(changed, column, m.tabStop)
+	}
+	return Position{
+		Line:   line,
+		Column: column + 1,
+		Offset: m.lineOffsets[line-1] + column,
+	}
+}
+
+// positionAfter returns the position in the input just after the byte at the
+// given 0-based column in the given line, as seen by the parser. If that byte
+// is the line ending, this is the start of the next line.
+func (m *sourceMap) positionAfter(line, column int) Position {
+	p := m.position(line, column)
+	if p.Line == 0 {
+		return p
+	}
+	if p.Column > m.lineLengths[line-1] && line < len(m.lineOffsets) {
+		return Position{Line: line + 1, Column: 1, Offset: m.lineOffsets[line]}
+	}
+	p.Column++
+	p.Offset++
+	return p
+}
+
+// lineEnd returns the position in the input just after the last byte of the
+// given line, not counting the line ending.
+func (m *sourceMap) lineEnd(line int) Position {
+	if line < 1 || line > len(m.lineOffsets) {
+		return Position{}
+	}
+	length := m.lineLengths[line-1]
+	return Position{
+		Line:   line,
+		Column: length + 1,
+		Offset: m.lineOffsets[line-1] + length,
+	}
+}
+
+// unexpandedColumn converts a 0-based column in the result of
+// replaceNULs(tabsToSpaces(line, tabStop)) to the corresponding column in
+// line. A column inside the spaces that a tab expanded to, or inside the
+// replacement character of a NUL, maps to the tab or NUL itself.
+func unexpandedColumn(line []byte, column, tabStop int) int {
+	var expanded, runeCount int
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRune(line[i:])
+		width := size
+		switch r {
+		case '\t':
+			width = tabStop - runeCount%tabStop
+			runeCount += width
+		case 0:
+			width = len(replacementChar)
+			runeCount++
+		default:
+			runeCount++
+		}
+		if column < expanded+width {
+			if r == '\t' || r == 0 {
+				return i
+			}
+			return i + column - expanded
+		}
+		expanded += width
+		i += size
+	}
+	return len(line) + column - expanded
+}
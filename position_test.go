@@ -0,0 +1,33 @@
+package commonmark
+
+import (
+	"testing"
+)
+
+func TestUnexpandedColumn(t *testing.T) {
+	tests := []struct {
+		line               string
+		column, unexpanded int
+	}{
+		{"foo", 1, 1},
+		{"foo", 5, 5},
+		{"\tfoo", 0, 0},
+		{"\tfoo", 3, 0},
+		{"\tfoo", 4, 1},
+		{"a\tb", 2, 1},
+		{"a\tb", 4, 2},
+		{"é\tb", 2, 2},
+		{"é\tb", 4, 2},
+		{"é\tb", 5, 3},
+		{"a\x00b", 1, 1},
+		{"a\x00b", 3, 1},
+		{"a\x00b", 4, 2},
+		{"\x00\tb", 4, 1},
+		{"\x00\tb", 6, 2},
+	}
+	for _, test := range tests {
+		if actual := unexpandedColumn([]byte(test.line), test.column, 4); actual != test.unexpanded {
+			t.Errorf("unexpandedColumn(%q, %d) = %d, expected %d", test.line, test.column, actual, test.unexpanded)
+		}
+	}
+}
@@ -3,14 +3,67 @@ package commonmark
 import (
 	"bufio"
 	"bytes"
-	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
 )
 
-// newScanner returns a new bufio.Scanner suitable for reading lines.
-func newScanner(data []byte) *bufio.Scanner {
-	scanner := bufio.NewScanner(bytes.NewReader(data))
-	scanner.Split(scanLines)
-	return scanner
+// byteOrderMark is the UTF-8 encoding of U+FEFF, which some editors put at the
+// start of a file.
+var byteOrderMark = []byte("\uFEFF")
+
+// lineScanner is a bufio.Scanner that reads lines, and keeps track of the
+// byte offset in the input at which each line starts.
+type lineScanner struct {
+	*bufio.Scanner
+	// consumed is the number of bytes of input consumed so far.
+	consumed int
+	// lineOffset is the offset of the current line in the input.
+	lineOffset int
+	// line is the current line.
+	line []byte
+	// started is true once the first line has been scanned.
+	started bool
+	// firstLineEnding is the line ending (CR, LF or CRLF) of the first line
+	// that has one, or empty if there is no such line yet.
+	firstLineEnding string
+}
+
+// newScanner returns a new lineScanner. Unlike a plain bufio.Scanner, it has
+// no limit on the length of a line; the buffer grows as needed.
+func newScanner(r io.Reader) *lineScanner {
+	s := &lineScanner{Scanner: bufio.NewScanner(r)}
+	s.Buffer(nil, math.MaxInt)
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := scanLines(data, atEOF)
+		s.consumed += advance
+		if s.firstLineEnding == "" && advance > len(token) {
+			s.firstLineEnding = string(data[len(token):advance])
+		}
+		return advance, token, err
+	})
+	return s
+}
+
+// Scan advances to the next line, like bufio.Scanner.Scan. A byte order mark
+// at the start of the input is skipped; anywhere else, it is left alone.
+func (s *lineScanner) Scan() bool {
+	s.lineOffset = s.consumed
+	if !s.Scanner.Scan() {
+		return false
+	}
+	s.line = s.Scanner.Bytes()
+	if !s.started && bytes.HasPrefix(s.line, byteOrderMark) {
+		s.line = s.line[len(byteOrderMark):]
+		s.lineOffset += len(byteOrderMark)
+	}
+	s.started = true
+	return true
+}
+
+// Bytes returns the current line, like bufio.Scanner.Bytes.
+func (s *lineScanner) Bytes() []byte {
+	return s.line
 }
 
 // scanLines is a split function for bufio.Scanner that splits on CR, LF or
@@ -22,6 +75,11 @@ func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	}
 	for i := 0; i < len(data); i++ {
 		if data[i] == '\r' {
+			// A CR at the end of the data may be the first half of a CRLF
+			// pair that was split across reads.
+			if i+1 == len(data) && !atEOF {
+				return 0, nil, nil
+			}
 			if i+1 < len(data) && data[i+1] == '\n' {
 				return i + 2, data[0:i], nil
 			} else {
@@ -39,13 +97,28 @@ func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	return 0, nil, nil
 }
 
-// tabsToSpaces returns a slice (possibly the same one) in which tabs have been
-// replaced by up to 4 spaces, depending on the tab stop position.
+// replacementChar is the UTF-8 encoding of U+FFFD REPLACEMENT CHARACTER.
+var replacementChar = []byte("\uFFFD")
+
+// replaceNULs returns a slice (possibly the same one) in which NUL characters
+// have been replaced by U+FFFD.
+//
+// "For security reasons, the Unicode character U+0000 must be replaced with
+// the REPLACEMENT CHARACTER (U+FFFD)."
 //
 // It does not modify the input slice; a copy is made if needed.
-func tabsToSpaces(line []byte) []byte {
-	const tabStop = 4
+func replaceNULs(line []byte) []byte {
+	if bytes.IndexByte(line, 0) < 0 {
+		return line
+	}
+	return bytes.Replace(line, []byte{0}, replacementChar, -1)
+}
 
+// tabsToSpaces returns a slice (possibly the same one) in which tabs have been
+// replaced by up to tabStop spaces, depending on the tab stop position.
+//
+// It does not modify the input slice; a copy is made if needed.
+func tabsToSpaces(line []byte, tabStop int) []byte {
 	var tabCount int
 	for _, c := range line {
 		if c == '\t' {
@@ -56,18 +129,24 @@ func tabsToSpaces(line []byte) []byte {
 		return line
 	}
 
-	output := make([]byte, 0, len(line)+3*tabCount)
+	// Tab stops are determined by the number of code points before the tab,
+	// not the number of bytes.
+	output := make([]byte, 0, len(line)+(tabStop-1)*tabCount)
 	var runeCount int
-	for _, c := range string(line) {
-		if c == '\t' {
+	for i := 0; i < len(line); {
+		if line[i] == '\t' {
 			numSpaces := tabStop - runeCount%tabStop
-			spaces := bytes.Repeat([]byte{' '}, numSpaces)
-			output = append(output, spaces...)
+			for j := 0; j < numSpaces; j++ {
+				output = append(output, ' ')
+			}
 			runeCount += numSpaces
-		} else {
-			output = append(output, []byte(fmt.Sprintf("%c", c))...)
-			runeCount++
+			i++
+			continue
 		}
+		_, size := utf8.DecodeRune(line[i:])
+		output = append(output, line[i:i+size]...)
+		runeCount++
+		i += size
 	}
 	return output
 }
@@ -0,0 +1,142 @@
+package commonmark
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestTabsToSpaces(t *testing.T) {
+	tests := []struct {
+		line     string
+		tabStop  int
+		expanded string
+	}{
+		{"foo", 4, "foo"},
+		{"\tfoo", 4, "    foo"},
+		{" \tfoo", 4, "    foo"},
+		{"   \tfoo", 4, "    foo"},
+		{"    \tfoo", 4, "        foo"},
+		{"a\tb\tc", 4, "a   b   c"},
+		{"\t", 4, "    "},
+		{"\t\t\t", 4, "            "},
+		{"\t\t", 2, "    "},
+		{"\t\t", 8, "                "},
+		{"\tfoo", 2, "  foo"},
+		{"a\tb", 2, "a b"},
+		{"ab\tc", 2, "ab  c"},
+		{"\t\tfoo", 2, "    foo"},
+		{"\tfoo", 8, "        foo"},
+		{"abc\td", 8, "abc     d"},
+		{"abcdefgh\ti", 8, "abcdefgh        i"},
+		{"a\t\tb", 8, "a               b"},
+		// Columns are counted in code points.
+		{"é\tb", 4, "é   b"},
+		{"café\tb", 4, "café    b"},
+		{"naïve\tb", 8, "naïve   b"},
+		{"日本\tb", 4, "日本  b"},
+		{"e\u0301\tb", 4, "e\u0301  b"},
+		// Invalid UTF-8 is passed through, one code point per byte.
+		{"\xff\xfe\tb", 4, "\xff\xfe  b"},
+	}
+	for _, test := range tests {
+		if actual := string(tabsToSpaces([]byte(test.line), test.tabStop)); actual != test.expanded {
+			t.Errorf("tabsToSpaces(%q, %d) = %q, expected %q", test.line, test.tabStop, actual, test.expanded)
+		}
+	}
+}
+
+func TestByteOrderMark(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"\uFEFF# foo\n", "<h1>foo</h1>\n"},
+		{"\uFEFFfoo\nbar\n", "<p>foo\nbar</p>\n"},
+		{"\uFEFF    code\n", "<pre><code>code\n</code></pre>\n"},
+		{"\uFEFF", ""},
+		// Only a single byte order mark at the very start is stripped.
+		{"\uFEFF\uFEFFfoo\n", "<p>\uFEFFfoo</p>\n"},
+		{"foo\n\uFEFFbar\n", "<p>foo\n\uFEFFbar</p>\n"},
+		{"foo \uFEFF bar\n", "<p>foo \uFEFF bar</p>\n"},
+	})
+
+	doc, err := ParseDocument([]byte("\uFEFF# foo\n"))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %s", err)
+	}
+	heading := doc.FirstChild()
+	if start := heading.StartPosition(); start != (Position{Line: 1, Column: 1, Offset: 3}) {
+		t.Errorf("heading starts at %+v, expected after the byte order mark", start)
+	}
+}
+
+func TestNUL(t *testing.T) {
+	runConversionTests(t, []conversionTest{
+		{"foo\x00bar\n", "<p>foo�bar</p>\n"},
+		{"\x00\n", "<p>�</p>\n"},
+		{"    a\x00b\n", "<pre><code>a�b\n</code></pre>\n"},
+		{"`\x00`\n", "<p><code>�</code></p>\n"},
+		{"[\x00]: /url\n\n[\x00]\n", "<p><a href=\"/url\">�</a></p>\n"},
+	})
+
+	doc, err := ParseDocument([]byte("a\x00*b*\n"))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %s", err)
+	}
+	emph := doc.FirstChild().LastChild()
+	if start := emph.StartPosition(); start != (Position{Line: 1, Column: 3, Offset: 2}) {
+		t.Errorf("emphasis starts at %+v, expected after the NUL", start)
+	}
+}
+
+func TestLongLines(t *testing.T) {
+	long := strings.Repeat("abc ", 50000)
+	runConversionTests(t, []conversionTest{
+		{long + "\n", "<p>" + long[:len(long)-1] + "</p>\n"},
+		{"foo\n" + long + "\n\nbar", "<p>foo\n" + long[:len(long)-1] + "</p>\n<p>bar</p>\n"},
+		{"<div>" + long + "</div>\n", "<div>" + long + "</div>\n"},
+		{"![x](data:image/png;base64," + strings.Repeat("A", 200000) + ")\n",
+			"<p><img src=\"data:image/png;base64," + strings.Repeat("A", 200000) + "\" alt=\"x\" /></p>\n"},
+	})
+
+	// Convert reads the input in chunks, so the line spans many of them.
+	var output bytes.Buffer
+	if err := Convert(&output, iotest.HalfReader(strings.NewReader("    "+long+"\r\nfoo"))); err != nil {
+		t.Fatalf("Convert returned error: %s", err)
+	}
+	if expected := "<pre><code>" + long + "\n</code></pre>\n<p>foo</p>\n"; output.String() != expected {
+		t.Errorf("incorrect output for long line read in chunks")
+	}
+}
+
+func TestCRLFSplitAcrossReads(t *testing.T) {
+	tests := []conversionTest{
+		{"foo\r\nbar\r\n", "<p>foo\nbar</p>\n"},
+		{"```\r\na\r\nb\r\n```\r\n", "<pre><code>a\nb\n</code></pre>\n"},
+		{"foo\rbar\r", "<p>foo\nbar</p>\n"},
+	}
+	for _, test := range tests {
+		var output bytes.Buffer
+		if err := Convert(&output, iotest.OneByteReader(strings.NewReader(test.input))); err != nil {
+			t.Errorf("Convert returned error for %q: %s", test.input, err)
+		} else if output.String() != test.output {
+			t.Errorf("incorrect output for %q read byte by byte\nexpected:\n%s\nactual:\n%s", test.input, test.output, output.String())
+		}
+	}
+
+	s := newScanner(iotest.OneByteReader(strings.NewReader("foo\r\nbar\n")))
+	for s.Scan() {
+	}
+	if s.firstLineEnding != "\r\n" {
+		t.Errorf("first line ending is %q, expected %q", s.firstLineEnding, "\r\n")
+	}
+
+	// The scanner starts with a buffer of 4096 bytes, so the CRLF pair is
+	// split between the first read and the next one.
+	line := strings.Repeat("a", 4095)
+	runConversionTests(t, []conversionTest{
+		{line + "\r\nb\r\n", "<p>" + line + "\nb</p>\n"},
+	})
+	runConversionTestsWithOptions(t, Options{LineEnding: LineEndingPreserve}, []conversionTest{
+		{line + "\r\nb\n", "<p>" + line + "\r\nb</p>\r\n"},
+	})
+}
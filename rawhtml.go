@@ -0,0 +1,129 @@
+package commonmark
+
+import (
+	"regexp"
+)
+
+// The building blocks of the HTML tag grammar, as regular expressions.
+const (
+	// "A tag name consists of an ASCII letter followed by zero or more ASCII
+	// letters, digits, or hyphens (-)."
+	tagNamePattern = `[A-Za-z][A-Za-z0-9-]*`
+	// "An attribute name consists of an ASCII letter, _, or :, followed by
+	// zero or more ASCII letters, digits, _, ., :, or -."
+	attributeNamePattern = `[a-zA-Z_:][a-zA-Z0-9_.:-]*`
+	// "An attribute value is either an unquoted attribute value, a
+	// single-quoted attribute value, or a double-quoted attribute value."
+	attributeValuePattern = `(?:[^"'=<>` + "`" + `\x00-\x20]+|'[^']*'|"[^"]*")`
+	// "An attribute value specification consists of optional spaces, tabs,
+	// and up to one line ending, a = character, optional spaces, tabs, and up
+	// to one line ending, and an attribute value."
+	attributeValueSpecPattern = `(?:[ \t]*\n?[ \t]*=[ \t]*\n?[ \t]*` + attributeValuePattern + `)`
+	// "An attribute consists of spaces, tabs, and up to one line ending, an
+	// attribute name, and an optional attribute value specification."
+	attributePattern = `(?:[ \t]*\n?[ \t]*[ \t\n]` + attributeNamePattern + attributeValueSpecPattern + `?)`
+
+	// "An open tag consists of a < character, a tag name, zero or more
+	// attributes, optional spaces, tabs, and up to one line ending, an
+	// optional / character, and a > character."
+	openTagPattern = `<` + tagNamePattern + attributePattern + `*[ \t]*\n?[ \t]*/?>`
+	// "A closing tag consists of the string </, a tag name, optional spaces,
+	// tabs, and up to one line ending, and the character >."
+	closingTagPattern = `</` + tagNamePattern + `[ \t]*\n?[ \t]*>`
+	// "An HTML comment consists of <!-->, <!--->, or <!--, a string of
+	// characters not including the string -->, and -->."
+	commentPattern = `<!-->|<!--->|<!--[\s\S]*?-->`
+	// "A processing instruction consists of the string <?, a string of
+	// characters not including the string ?>, and the string ?>."
+	processingInstructionPattern = `<\?[\s\S]*?\?>`
+	// "A declaration consists of the string <!, an ASCII letter, zero or more
+	// characters not including the character >, and the character >."
+	declarationPattern = `<![A-Za-z][^>]*>`
+	// "A CDATA section consists of the string <![CDATA[, a string of
+	// characters not including the string ]]>, and the string ]]>."
+	cdataPattern = `<!\[CDATA\[[\s\S]*?\]\]>`
+)
+
+var rawHTMLRe = regexp.MustCompile(`^(?:` + openTagPattern + `|` + closingTagPattern + `|` +
+	commentPattern + `|` + processingInstructionPattern + `|` + declarationPattern + `|` +
+	cdataPattern + `)`)
+
+// parseRawHTML returns the length of the HTML tag at the start of data, or 0
+// if data does not start with an HTML tag.
+//
+// "Text between < and > that looks like an HTML tag is parsed as a raw HTML
+// tag and will be rendered in HTML without escaping. Tag and attribute names
+// are not limited to current HTML tags, so custom tags (and even, say, DocBook
+// tags) may be used."
+func parseRawHTML(data []byte) int {
+	return len(rawHTMLRe.Find(data))
+}
+
+// htmlBlockStartRes are the regular expressions for the start conditions of
+// the seven kinds of HTML blocks. The regular expression for the start
+// condition of kind i is at index i-1.
+var htmlBlockStartRes = []*regexp.Regexp{
+	// "Start condition: line begins with the string <pre, <script, <style, or
+	// <textarea (case-insensitive), followed by a space, a tab, the string >,
+	// or the end of the line."
+	regexp.MustCompile(`^ {0,3}<(?i:pre|script|style|textarea)[ \t\n>]`),
+	// "Start condition: line begins with the string <!--."
+	regexp.MustCompile(`^ {0,3}<!--`),
+	// "Start condition: line begins with the string <?."
+	regexp.MustCompile(`^ {0,3}<\?`),
+	// "Start condition: line begins with the string <! followed by an ASCII
+	// letter."
+	regexp.MustCompile(`^ {0,3}<![A-Za-z]`),
+	// "Start condition: line begins with the string <![CDATA[."
+	regexp.MustCompile(`^ {0,3}<!\[CDATA\[`),
+	// "Start condition: line begins with the string < or </ followed by one of
+	// the strings (case-insensitive) address, article, aside, [...], followed
+	// by a space, a tab, the end of the line, the string >, or the string />."
+	regexp.MustCompile(`^ {0,3}</?(?i:address|article|aside|base|basefont|blockquote|body|caption|center|col|colgroup|dd|details|dialog|dir|div|dl|dt|fieldset|figcaption|figure|footer|form|frame|frameset|h1|h2|h3|h4|h5|h6|head|header|hr|html|iframe|legend|li|link|main|menu|menuitem|nav|noframes|ol|optgroup|option|p|param|search|section|summary|table|tbody|td|tfoot|th|thead|title|tr|track|ul)(?:[ \t\n>]|/>)`),
+	// "Start condition: line begins with a complete open tag or closing tag
+	// (with any tag name other than pre, script, style, or textarea) followed
+	// only by spaces, tabs, or the end of the line."
+	regexp.MustCompile(`^ {0,3}(?:` + openTagPattern + `|` + closingTagPattern + `)[ \t]*\n$`),
+}
+
+// htmlBlockTagNameRe matches the tag names that are excluded from the start
+// condition of HTML blocks of kind 7.
+var htmlBlockTagNameRe = regexp.MustCompile(`^ {0,3}</?(?i:pre|script|style|textarea)(?:[^A-Za-z0-9-]|$)`)
+
+// htmlBlockEndRes are the regular expressions for the end conditions of the
+// first five kinds of HTML blocks. The last two kinds end at a blank line.
+var htmlBlockEndRes = []*regexp.Regexp{
+	// "End condition: line contains an end tag </pre>, </script>, </style>, or
+	// </textarea> (case-insensitive; it need not match the start tag)."
+	regexp.MustCompile(`(?i)</(?:pre|script|style|textarea)>`),
+	// "End condition: line contains the string -->."
+	regexp.MustCompile(`-->`),
+	// "End condition: line contains the string ?>."
+	regexp.MustCompile(`\?>`),
+	// "End condition: line contains the character >."
+	regexp.MustCompile(`>`),
+	// "End condition: line contains the string ]]>."
+	regexp.MustCompile(`\]\]>`),
+}
+
+// parseHTMLBlockStart returns the kind (1-7) of the HTML block that starts on
+// the given line, or 0 if the line does not start an HTML block.
+//
+// "An HTML block is a group of lines that is treated as raw HTML (and will not
+// be escaped in HTML output)."
+func parseHTMLBlockStart(line []byte) int {
+	for i, re := range htmlBlockStartRes {
+		kind := i + 1
+		if re.Match(line) && !(kind == 7 && htmlBlockTagNameRe.Match(line)) {
+			return kind
+		}
+	}
+	return 0
+}
+
+// endsHTMLBlock returns whether the given line ends an HTML block of the given
+// kind. Blocks of kinds 6 and 7 are ended by a blank line instead, which is
+// not part of the block.
+func endsHTMLBlock(kind int, line []byte) bool {
+	return kind <= len(htmlBlockEndRes) && htmlBlockEndRes[kind-1].Match(line)
+}
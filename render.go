@@ -0,0 +1,68 @@
+package commonmark
+
+import (
+	"io"
+)
+
+// Renderer converts a tree of nodes to some output format.
+type Renderer interface {
+	// RenderNode writes the output for a single node to w. It is called
+	// twice for each node: once with entering set to true before the
+	// children of the node are rendered, and once with entering set to false
+	// after that. The returned WalkStatus controls the rest of the rendering
+	// in the same way as for Walk. If an error is returned, rendering stops.
+	RenderNode(w io.Writer, n *Node, entering bool) (WalkStatus, error)
+}
+
+// Render renders the tree rooted at root to w, using the given renderer. The
+// first error returned by the renderer or by w is returned.
+func Render(w io.Writer, root *Node, r Renderer) error {
+	out := &errWriter{w: w}
+	var err error
+	walkErr := Walk(root, func(n *Node, entering bool) WalkStatus {
+		var status WalkStatus
+		status, err = r.RenderNode(out, n, entering)
+		if err != nil || out.err != nil {
+			return WalkStop
+		}
+		return status
+	})
+	if err != nil {
+		return err
+	}
+	if out.err != nil {
+		return out.err
+	}
+	return walkErr
+}
+
+// WriteTo renders the tree rooted at n as HTML to w, implementing io.WriterTo.
+// The output is written to w as it is produced. The options that the document
+// was parsed with are used, or the zero Options if the tree was not created by
+// ParseDocument or ParseDocumentWithOptions. To render with other options,
+// use Render with NewHTMLRenderer.
+func (n *Node) WriteTo(w io.Writer) (int64, error) {
+	root := n
+	for root.parent != nil {
+		root = root.parent
+	}
+	var options Options
+	if root.options != nil {
+		options = *root.options
+	}
+	out := &countingWriter{w: w}
+	err := Render(out, n, NewHTMLRenderer(options))
+	return out.n, err
+}
+
+// countingWriter wraps an io.Writer and counts the bytes written to it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
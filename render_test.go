@@ -0,0 +1,162 @@
+package commonmark
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// headingCounter is a Renderer that writes nothing, but counts the headings.
+type headingCounter struct {
+	count int
+}
+
+func (c *headingCounter) RenderNode(w io.Writer, n *Node, entering bool) (WalkStatus, error) {
+	if n.Type == Heading && entering {
+		c.count++
+	}
+	return WalkContinue, nil
+}
+
+func TestRender(t *testing.T) {
+	doc, err := ParseDocument([]byte("# a\n\nb\n\nc\n---\n\n> # d\n"))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %s", err)
+	}
+	var out bytes.Buffer
+	counter := &headingCounter{}
+	if err := Render(&out, doc, counter); err != nil {
+		t.Errorf("Render returned error: %s", err)
+	}
+	if counter.count != 3 {
+		t.Errorf("counted %d headings, expected 3", counter.count)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output, got %q", out.String())
+	}
+}
+
+// upperCodeRenderer renders code spans itself, and delegates to the HTML
+// renderer for everything else.
+type upperCodeRenderer struct {
+	html Renderer
+}
+
+func (r upperCodeRenderer) RenderNode(w io.Writer, n *Node, entering bool) (WalkStatus, error) {
+	if n.Type == Code {
+		if entering {
+			fmt.Fprintf(w, "<kbd>%s</kbd>", bytes.ToUpper(n.Literal()))
+		}
+		return WalkContinue, nil
+	}
+	return r.html.RenderNode(w, n, entering)
+}
+
+func TestRenderDelegation(t *testing.T) {
+	doc, err := ParseDocument([]byte("- press `q`\n- ![`x`](y)\n"))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %s", err)
+	}
+	var out bytes.Buffer
+	if err := Render(&out, doc, upperCodeRenderer{NewHTMLRenderer(Options{})}); err != nil {
+		t.Errorf("Render returned error: %s", err)
+	}
+	expected := "<ul>\n<li>press <kbd>Q</kbd></li>\n<li><img src=\"y\" alt=\"x\" /></li>\n</ul>\n"
+	if out.String() != expected {
+		t.Errorf("got %q, expected %q", out.String(), expected)
+	}
+}
+
+// failingRenderer fails when entering a node of the given type.
+type failingRenderer struct {
+	nodeType NodeType
+}
+
+func (r failingRenderer) RenderNode(w io.Writer, n *Node, entering bool) (WalkStatus, error) {
+	if n.Type == r.nodeType && entering {
+		return WalkStop, errors.New("failed")
+	}
+	io.WriteString(w, n.Type.String()+"\n")
+	return WalkContinue, nil
+}
+
+func TestRenderErrors(t *testing.T) {
+	doc, err := ParseDocument([]byte("a\n\nb\n"))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %s", err)
+	}
+	var out bytes.Buffer
+	err = Render(&out, doc, failingRenderer{Text})
+	if err == nil || err.Error() != "failed" {
+		t.Errorf("expected renderer error, got %v", err)
+	}
+	if out.String() != "Document\nParagraph\n" {
+		t.Errorf("rendering did not stop at the error, got %q", out.String())
+	}
+
+	err = Render(failingWriter{}, doc, NewHTMLRenderer(Options{}))
+	if err != errWriteFailed {
+		t.Errorf("expected write error %q, got %v", errWriteFailed, err)
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	inputs := []string{
+		"# Title\n\nSome *text* with [a link][x].\n\n- one\n- two\n\n[x]: /url\n",
+		"> quote\n\n```go\ncode\n```\n",
+		"",
+	}
+	for _, input := range inputs {
+		expected, err := ToHTMLBytes([]byte(input))
+		if err != nil {
+			t.Fatalf("ToHTMLBytes(%q) returned error: %s", input, err)
+		}
+		doc, err := ParseDocument([]byte(input))
+		if err != nil {
+			t.Fatalf("ParseDocument(%q) returned error: %s", input, err)
+		}
+		var output bytes.Buffer
+		n, err := doc.WriteTo(&output)
+		if err != nil {
+			t.Errorf("WriteTo for %q returned error: %s", input, err)
+		}
+		if !bytes.Equal(output.Bytes(), expected) {
+			t.Errorf("WriteTo for %q wrote %q, but ToHTMLBytes returned %q", input, output.Bytes(), expected)
+		}
+		if n != int64(output.Len()) {
+			t.Errorf("WriteTo for %q returned %d, but wrote %d bytes", input, n, output.Len())
+		}
+	}
+
+	// The options that the document was parsed with are used.
+	input := []byte("<b>~~foo~~</b>\n")
+	options := Options{Safe: true, Strikethrough: true}
+	expected, err := ToHTMLBytesWithOptions(input, options)
+	if err != nil {
+		t.Fatalf("ToHTMLBytesWithOptions returned error: %s", err)
+	}
+	doc, err := ParseDocumentWithOptions(input, options)
+	if err != nil {
+		t.Fatalf("ParseDocumentWithOptions returned error: %s", err)
+	}
+	var output bytes.Buffer
+	if _, err := doc.WriteTo(&output); err != nil {
+		t.Errorf("WriteTo returned error: %s", err)
+	}
+	if !bytes.Equal(output.Bytes(), expected) {
+		t.Errorf("WriteTo wrote %q, but ToHTMLBytesWithOptions returned %q", output.Bytes(), expected)
+	}
+	output.Reset()
+	if _, err := doc.FirstChild().FirstChild().Next().WriteTo(&output); err != nil {
+		t.Errorf("WriteTo returned error: %s", err)
+	}
+	if actual := output.String(); actual != "<del>foo</del>" {
+		t.Errorf("WriteTo for a child node wrote %q, want %q", actual, "<del>foo</del>")
+	}
+
+	if _, err := doc.WriteTo(failingWriter{}); err != errWriteFailed {
+		t.Errorf("expected write error %q, got %v", errWriteFailed, err)
+	}
+}
@@ -5,3 +5,6 @@ cd $(dirname "$0")/..
 
 # Fetch the latest spec
 curl -o spec.txt 'https://raw.githubusercontent.com/jgm/CommonMark/master/spec.txt'
+
+# Fetch the examples of the current spec, for TestSpecJSON
+curl -o spec.json 'https://spec.commonmark.org/current/spec.json'
@@ -0,0 +1,87 @@
+package commonmark
+
+import (
+	"bytes"
+	"strconv"
+	"unicode"
+)
+
+// assignHeadingIDs gives each header in the document an id derived from its
+// text, in the way GitHub does. Headers whose text has no letters or digits
+// get no id. The ids in slugs are taken already, and the new ones are added.
+// If custom is not nil, it is used to derive the ids instead of slugify.
+func assignHeadingIDs(doc *document, slugs slugSet, custom func(text string) string) {
+	var assign func(b Block)
+	assign = func(b Block) {
+		if h, ok := b.(*atxHeader); ok {
+			var text bytes.Buffer
+			plainText(h.inlineContent, &text)
+			if custom != nil {
+				h.id = slugs.unique(custom(string(bytes.TrimSpace(text.Bytes()))))
+			} else {
+				h.id = slugs.unique(slugify(text.Bytes()))
+			}
+		}
+		for _, child := range b.Children() {
+			assign(child)
+		}
+	}
+	assign(doc)
+}
+
+// plainText writes the text content of an inline to text, without any
+// markup.
+func plainText(i Inline, text *bytes.Buffer) {
+	switch t := i.(type) {
+	case *stringInline:
+		text.Write(t.content)
+	case *codeSpan:
+		text.Write(t.content)
+	case *texMath:
+		text.Write(t.content)
+	case *softLineBreak, *hardLineBreak:
+		text.WriteByte(' ')
+	}
+	for _, child := range inlineChildren(i) {
+		plainText(child, text)
+	}
+}
+
+// slugify turns text into an identifier that can be used in a URL fragment:
+// letters are lowercased, spaces become hyphens, and punctuation and other
+// symbols are dropped. Letters and digits in any script are kept.
+func slugify(text []byte) string {
+	var slug []rune
+	for _, r := range string(bytes.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r) || r == '-' || r == '_':
+			slug = append(slug, unicode.ToLower(r))
+		case unicode.IsSpace(r):
+			slug = append(slug, '-')
+		}
+	}
+	return string(slug)
+}
+
+// slugSet holds the slugs that have been used in a document, and the number
+// of times each one was used as the base of a numbered slug.
+type slugSet map[string]int
+
+// unique returns the slug, or if it has been used already, the slug with the
+// lowest numeric suffix (-1, -2, ...) that makes it unique. An empty slug is
+// returned as is.
+func (s slugSet) unique(slug string) string {
+	if slug == "" {
+		return ""
+	}
+	unique := slug
+	for {
+		if _, used := s[unique]; !used {
+			break
+		}
+		s[slug]++
+		unique = slug + "-" + strconv.Itoa(s[slug])
+	}
+	s[unique] = 0
+	return unique
+}
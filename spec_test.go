@@ -3,13 +3,17 @@ package commonmark
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"go/build"
+	"html"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -26,7 +30,7 @@ type result struct {
 }
 
 func TestSpec(t *testing.T) {
-	specFile, err := openSpecFile()
+	specFile, err := openSpecFile("spec.txt")
 	if err != nil {
 		t.Fatalf("error loading spec.txt: %s", err)
 	}
@@ -34,9 +38,7 @@ func TestSpec(t *testing.T) {
 	examples := make(chan example)
 	go readExamples(specFile, examples)
 
-	var res result
-	var sections []string
-	sectionResults := make(map[string]*result)
+	var results specResults
 	for ex := range examples {
 		var failed bool
 		actualOutput, err := ToHTMLBytes(ex.input)
@@ -49,34 +51,220 @@ func TestSpec(t *testing.T) {
 				ex.section, ex.number, ex.input, ex.output, actualOutput)
 		}
 
-		if sectionResults[ex.section] == nil {
-			sections = append(sections, ex.section)
-			sectionResults[ex.section] = &result{}
-		}
+		results.add(ex.section, failed)
+	}
+	t.Log(results.report())
+}
+
+// specResults counts the examples that were run and that failed, in total and
+// per section.
+type specResults struct {
+	total     result
+	sections  []string
+	bySection map[string]*result
+}
+
+// add records the result of an example in the given section.
+func (r *specResults) add(section string, failed bool) {
+	if r.bySection == nil {
+		r.bySection = make(map[string]*result)
+	}
+	if r.bySection[section] == nil {
+		r.sections = append(r.sections, section)
+		r.bySection[section] = &result{}
+	}
+	for _, res := range []*result{&r.total, r.bySection[section]} {
 		res.run++
-		sectionResults[ex.section].run++
 		if failed {
 			res.failed++
-			sectionResults[ex.section].failed++
 		}
 	}
+}
+
+// report returns a table of the counts, with a line for each section in the
+// order in which they were first seen.
+func (r *specResults) report() string {
 	output := "spec test complete\n"
 	output += fmt.Sprintf("%-28s   CNT  PASS  FAIL\n", "")
-	for _, section := range sections {
-		res := sectionResults[section]
+	for _, section := range r.sections {
+		res := r.bySection[section]
 		output += fmt.Sprintf("%-28s   %3d   %3d   %3d\n", section, res.run, res.run-res.failed, res.failed)
 	}
-	output += fmt.Sprintf("%-28s   %3d   %3d   %3d\n", "TOTAL", res.run, res.run-res.failed, res.failed)
-	t.Log(output)
+	output += fmt.Sprintf("%-28s   %3d   %3d   %3d\n", "TOTAL", r.total.run, r.total.run-r.total.failed, r.total.failed)
+	return output
+}
+
+// TestSpecJSON runs the examples in spec.json, the machine-readable form of
+// the spec that is published alongside it, and compares the output after
+// normalizing the HTML in the way the reference implementation's test suite
+// does. It is skipped if spec.json has not been downloaded; see
+// scripts/update_spec.sh. Each example is a subtest named after its section
+// and number, so a section can be selected with, for example,
+// go test -run 'TestSpecJSON/Tabs/'.
+func TestSpecJSON(t *testing.T) {
+	specFile, err := openSpecFile("spec.json")
+	if os.IsNotExist(err) {
+		t.Skip("spec.json not found")
+	}
+	if err != nil {
+		t.Fatalf("error loading spec.json: %s", err)
+	}
+	defer specFile.Close()
+	var examples []struct {
+		Markdown string
+		HTML     string
+		Example  int
+		Section  string
+	}
+	if err := json.NewDecoder(specFile).Decode(&examples); err != nil {
+		t.Fatalf("error reading spec.json: %s", err)
+	}
+
+	var results specResults
+	for _, ex := range examples {
+		name := fmt.Sprintf("%s/%d", ex.Section, ex.Example)
+		ran := false
+		failed := !t.Run(name, func(t *testing.T) {
+			ran = true
+			actualOutput, err := ToHTMLBytes([]byte(ex.Markdown))
+			if err != nil {
+				t.Fatalf("error: %s\ninput:\n%s", err, ex.Markdown)
+			}
+			if normalizeHTML(string(actualOutput)) != normalizeHTML(ex.HTML) {
+				t.Errorf("incorrect output\ninput:\n%s\nexpected output:\n%s\nactual output:\n%s",
+					ex.Markdown, ex.HTML, actualOutput)
+			}
+		})
+		// Examples that were filtered out with -run are not counted.
+		if ran {
+			results.add(ex.Section, failed)
+		}
+	}
+	t.Log(results.report())
+}
+
+var (
+	htmlTokenRegexp     = regexp.MustCompile(`<!--[\s\S]*?-->|<[^>]*>`)
+	htmlTagRegexp       = regexp.MustCompile(`^<(/?)([A-Za-z][A-Za-z0-9-]*)(\s[^>]*?)?\s*/?>$`)
+	htmlAttributeRegexp = regexp.MustCompile(`([^\s"'=/>]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+	whitespaceRegexp    = regexp.MustCompile(`\s+`)
+)
+
+// htmlBlockTags are the tags around which whitespace is insignificant.
+var htmlBlockTags = map[string]bool{
+	"blockquote": true, "body": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"hr": true, "html": true, "li": true, "ol": true, "p": true, "pre": true,
+	"section": true, "table": true, "tbody": true, "td": true, "th": true,
+	"thead": true, "tr": true, "ul": true,
+}
+
+// normalizeHTML normalizes HTML for comparison, so that the spec examples do
+// not depend on irrelevant details of the output: tag names are lowercased,
+// attributes are sorted and double quoted, void elements lose their slash,
+// entities are decoded and reencoded, whitespace outside <pre> is collapsed,
+// and whitespace around block-level tags is removed.
+func normalizeHTML(s string) string {
+	type token struct {
+		text  string
+		tag   string
+		block bool
+	}
+	var tokens []token
+	inPre := false
+	addText := func(text string) {
+		text = html.UnescapeString(text)
+		if !inPre {
+			text = whitespaceRegexp.ReplaceAllString(text, " ")
+		}
+		text = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(text)
+		tokens = append(tokens, token{text: text})
+	}
+	start := 0
+	for _, loc := range htmlTokenRegexp.FindAllStringIndex(s, -1) {
+		addText(s[start:loc[0]])
+		start = loc[1]
+		raw := s[loc[0]:loc[1]]
+		m := htmlTagRegexp.FindStringSubmatch(raw)
+		if m == nil {
+			tokens = append(tokens, token{tag: raw})
+			continue
+		}
+		name := strings.ToLower(m[2])
+		var attrs []string
+		for _, a := range htmlAttributeRegexp.FindAllStringSubmatch(m[3], -1) {
+			attr := strings.ToLower(a[1])
+			if value := a[2] + a[3] + a[4]; value != "" || strings.Contains(a[0], "=") {
+				attr += `="` + strings.Replace(html.UnescapeString(value), `"`, "&quot;", -1) + `"`
+			}
+			attrs = append(attrs, attr)
+		}
+		sort.Strings(attrs)
+		tag := "<" + m[1] + name
+		for _, attr := range attrs {
+			tag += " " + attr
+		}
+		tag += ">"
+		if name == "pre" {
+			inPre = m[1] == ""
+		}
+		tokens = append(tokens, token{tag: tag, block: htmlBlockTags[name]})
+	}
+	addText(s[start:])
+
+	var out bytes.Buffer
+	for i, tok := range tokens {
+		if tok.tag != "" {
+			out.WriteString(tok.tag)
+			continue
+		}
+		text := tok.text
+		if i == 0 || tokens[i-1].block {
+			text = strings.TrimLeft(text, " \n")
+		}
+		if i == len(tokens)-1 || tokens[i+1].block {
+			text = strings.TrimRight(text, " \n")
+		}
+		out.WriteString(text)
+	}
+	return out.String()
+}
+
+func TestNormalizeHTML(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"<p>foo</p>\n<hr />\n", "<p>foo</p><hr>"},
+		{"<P CLASS='a' id=b>x  y</P>", "<p class=\"a\" id=\"b\">x y</p>"},
+		{"<a title=\"t\" href=\"/u\">&#65;&amp;</a>", "<a href=\"/u\" title=\"t\">A&amp;</a>"},
+		{"<ul>\n<li>\n<p>a</p>\n</li>\n</ul>", "<ul><li><p>a</p></li></ul>"},
+		{"<input checked=\"\" disabled>", "<input checked=\"\" disabled>"},
+		{"<!-- a  b -->", "<!-- a  b -->"},
+	}
+	for _, test := range tests {
+		if a, b := normalizeHTML(test.a), normalizeHTML(test.b); a != b {
+			t.Errorf("normalizeHTML(%q) = %q, but normalizeHTML(%q) = %q", test.a, a, test.b, b)
+		}
+	}
+	different := [][2]string{
+		{"<pre><code>a  b</code></pre>", "<pre><code>a b</code></pre>"},
+		{"<em>a</em> b", "<em>a</em>b"},
+		{"<p>a</p>", "<p>b</p>"},
+	}
+	for _, test := range different {
+		if normalizeHTML(test[0]) == normalizeHTML(test[1]) {
+			t.Errorf("normalizeHTML(%q) = normalizeHTML(%q) = %q", test[0], test[1], normalizeHTML(test[0]))
+		}
+	}
 }
 
-func openSpecFile() (*os.File, error) {
+func openSpecFile(name string) (*os.File, error) {
 	pkg, err := build.Import("github.com/ttencate/commonmark", "", build.FindOnly)
 	if err != nil {
 		return nil, err
 	}
 
-	filename := filepath.Join(pkg.Root, "spec.txt")
+	filename := filepath.Join(pkg.Dir, name)
 
 	return os.Open(filename)
 }
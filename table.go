@@ -0,0 +1,199 @@
+package commonmark
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// table is a table, with the tables extension of GitHub Flavored Markdown. Its
+// children are tableRows, the first of which is the header row.
+//
+// "A table is an arrangement of data with rows and columns, consisting of a
+// single header row, a delimiter row separating the header from the data, and
+// zero or more data rows."
+type table struct {
+	block
+	// alignments holds the alignment of each column: "left", "center",
+	// "right" or "" if none was specified.
+	alignments []string
+}
+
+func (t *table) AcceptsLines() bool {
+	return true
+}
+
+// tableRow is a row of a table. Its children are tableCells.
+type tableRow struct {
+	block
+	// header is true for the header row.
+	header bool
+}
+
+// tableCell is a cell of a table, which contains inline content.
+type tableCell struct {
+	block
+	alignment string
+}
+
+var tableDelimiterCellRe = regexp.MustCompile(`^:?-+:?$`)
+
+// parseTableDelimiterRow recognizes the delimiter row of a table, and returns
+// the alignments of the columns. It returns nil if the line is not a
+// delimiter row.
+//
+// "The delimiter row consists of cells whose only content are hyphens (-), and
+// optionally, a leading or trailing colon (:), or both, to indicate left,
+// right, or center alignment respectively."
+func parseTableDelimiterRow(line []byte) []string {
+	cells := splitTableRow(line)
+	if len(cells) == 0 {
+		return nil
+	}
+	alignments := make([]string, len(cells))
+	for i, cell := range cells {
+		if !tableDelimiterCellRe.Match(cell) {
+			return nil
+		}
+		left, right := cell[0] == ':', cell[len(cell)-1] == ':'
+		switch {
+		case left && right:
+			alignments[i] = "center"
+		case left:
+			alignments[i] = "left"
+		case right:
+			alignments[i] = "right"
+		}
+	}
+	return alignments
+}
+
+// splitTableRow splits a line into the contents of its cells, stripped of
+// surrounding spaces. The returned slices share the backing array of the
+// line.
+//
+// "Each row consists of cells containing arbitrary text, in which inlines are
+// parsed, separated by pipes (|). A leading and trailing pipe is also
+// recommended for clarity of reading, and if there’s otherwise parsing
+// ambiguity."
+func splitTableRow(line []byte) [][]byte {
+	line = bytes.Trim(line, " \n")
+	if len(line) > 0 && line[0] == '|' {
+		line = line[1:]
+	}
+	var cells [][]byte
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			// The escaped character cannot be a cell separator.
+			i++
+		case '|':
+			cells = append(cells, bytes.Trim(line[start:i], " "))
+			start = i + 1
+		}
+	}
+	// The trailing pipe, if any, does not start another cell.
+	if start < len(line) || len(cells) == 0 && len(line) > 0 {
+		cells = append(cells, bytes.Trim(line[start:], " "))
+	}
+	return cells
+}
+
+// startTable checks whether the line is the delimiter row of a table whose
+// header row is the last line of the given paragraph. If so, it starts the
+// table, removing the header row from the paragraph, and returns true.
+//
+// "The header row must match the delimiter row in the number of cells. If
+// not, a table will not be recognized."
+func (p *blockParser) startTable(par *paragraph, line []byte) bool {
+	alignments := parseTableDelimiterRow(line)
+	if alignments == nil {
+		return false
+	}
+	// Link reference definitions are not part of the header.
+	p.extractReferenceDefinitions(par)
+	if isBlank(par.content) {
+		return false
+	}
+	headerStart := bytes.LastIndexByte(par.content[:len(par.content)-1], '\n') + 1
+	header := par.content[headerStart:]
+	if len(splitTableRow(header)) != len(alignments) {
+		return false
+	}
+	headerLine := par.contentLines[len(par.contentLines)-1]
+	assertf(headerLine.offset == headerStart, "header row starts at offset %d, not %d", headerLine.offset, headerStart)
+
+	t := &table{alignments: alignments}
+	if headerStart == 0 {
+		p.replaceOpenBlock(t)
+	} else {
+		// The lines before the header row remain a paragraph.
+		par.content = par.content[:headerStart]
+		par.contentLines = par.contentLines[:len(par.contentLines)-1]
+		par.endLine = headerLine.line - 1
+		p.closeLastBlock()
+		p.lastMatched--
+		p.addChild(t, 0)
+	}
+	t.startLine, t.startColumn = headerLine.line, headerLine.column
+	t.endLine = p.lineNumber
+	addTableRow(t, header, headerLine.line, headerLine.column, true)
+	return true
+}
+
+// addTableRow adds a row to the table, consisting of the cells in the given
+// line, which starts at the given line number and column.
+//
+// "The remainder of the table’s rows may vary in the number of cells. If a
+// number of cells fewer than the number of cells in the header row, empty
+// cells are inserted. If greater, the excess is ignored."
+func addTableRow(t *table, line []byte, lineNumber, column int, header bool) {
+	row := &tableRow{header: header}
+	row.startLine, row.endLine, row.startColumn = lineNumber, lineNumber, column
+	cells := splitTableRow(line)
+	for i, alignment := range t.alignments {
+		cell := &tableCell{alignment: alignment}
+		cell.startLine, cell.endLine = lineNumber, lineNumber
+		if i < len(cells) {
+			cellColumn := column + cap(line) - cap(cells[i])
+			cell.startColumn = cellColumn
+			appendTableCellContent(cell, cells[i], lineNumber, cellColumn)
+		} else {
+			cell.startColumn = column + len(bytes.TrimRight(line, " \n"))
+		}
+		row.AppendChild(cell)
+	}
+	t.AppendChild(row)
+}
+
+// appendTableCellContent sets the content of the cell, which starts at the
+// given line number and column.
+//
+// "It is possible to include a pipe in a cell’s content by escaping it,
+// including inside other inline spans."
+func appendTableCellContent(cell *tableCell, content []byte, lineNumber, column int) {
+	start := 0
+	for i := 0; i < len(content); i++ {
+		if content[i] != '\\' || i+1 >= len(content) {
+			continue
+		}
+		if content[i+1] == '|' {
+			// Drop the backslash, and record where the rest of the content
+			// came from.
+			cell.content = append(cell.content, content[start:i]...)
+			cell.contentLines = append(cell.contentLines, contentLine{
+				offset: len(cell.content),
+				line:   lineNumber,
+				column: column + i + 1,
+			})
+			start = i + 1
+		}
+		i++
+	}
+	if start == 0 {
+		cell.content = content
+	} else {
+		cell.content = append(cell.content, content[start:]...)
+	}
+	cell.contentLines = append([]contentLine{{0, lineNumber, column}}, cell.contentLines...)
+}
@@ -0,0 +1,69 @@
+package commonmark
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+// TextContent returns the plain text in the tree rooted at root, without any
+// markup: the contents of text nodes, code spans, math and code blocks, and
+// the alt text of images. Raw HTML is left out. Line breaks within a paragraph
+// become spaces, and each paragraph, heading, table cell, definition term and
+// code block is followed by a newline.
+func TextContent(root *Node) string {
+	return TextContentWithOptions(root, TextContentOptions{})
+}
+
+// TextContentOptions configures TextContentWithOptions.
+type TextContentOptions struct {
+	// SkipCodeBlocks leaves out the contents of code blocks, for example to
+	// count only the words of the prose. Code spans are still included.
+	SkipCodeBlocks bool
+}
+
+// TextContentWithOptions is like TextContent, but allows some of the text to
+// be left out. TextContent is equivalent to passing the zero value of
+// TextContentOptions.
+func TextContentWithOptions(root *Node, options TextContentOptions) string {
+	var text bytes.Buffer
+	Walk(root, func(n *Node, entering bool) WalkStatus {
+		switch n.Type {
+		case Text, Code, Math:
+			if entering {
+				text.Write(n.literal)
+			}
+		case CodeBlock:
+			if entering && !options.SkipCodeBlocks {
+				text.Write(n.literal)
+			}
+		case SoftBreak, HardBreak:
+			if entering {
+				text.WriteByte(' ')
+			}
+		case Paragraph, Heading, TableCell, DefinitionTerm:
+			if !entering {
+				text.WriteByte('\n')
+			}
+		}
+		return WalkContinue
+	})
+	return text.String()
+}
+
+// WordCount returns the number of words in the TextContent of the tree rooted
+// at root. Words are separated by white space, and must contain at least one
+// letter or digit; a lone "-" or "*" is not a word.
+func WordCount(root *Node) int {
+	count := 0
+	for _, field := range strings.Fields(TextContent(root)) {
+		if strings.IndexFunc(field, isWordChar) >= 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
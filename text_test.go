@@ -0,0 +1,76 @@
+package commonmark
+
+import (
+	"testing"
+)
+
+func TestTextContent(t *testing.T) {
+	tests := []struct {
+		input, text string
+		words       int
+	}{
+		{"", "", 0},
+		{"Some *emphasized* and __strong__ text.\n", "Some emphasized and strong text.\n", 5},
+		{"A [link](/url \"title\") and ![an *image*](/img.png).\n", "A link and an image.\n", 5},
+		{"Use `go build`, then\nrun it  \nagain.\n", "Use go build, then run it again.\n", 7},
+		{"<b>HTML</b> is <i>ignored</i>\n\n<div>\nblock\n</div>\n", "HTML is ignored\n", 3},
+		{"# Title\n\n> quote -- really\n\n- one\n- two\n", "Title\nquote -- really\none\ntwo\n", 5},
+		{"    code block\n\n```\nfenced\n```\n", "code block\nfenced\n", 3},
+		{"Don't count e-mail or 3.14 twice; * - are not words.\n", "Don't count e-mail or 3.14 twice; * - are not words.\n", 9},
+		{"Привет, мир! 日本語\n", "Привет, мир! 日本語\n", 3},
+	}
+	for _, test := range tests {
+		doc, err := ParseDocument([]byte(test.input))
+		if err != nil {
+			t.Fatalf("ParseDocument(%q) returned error: %s", test.input, err)
+		}
+		if text := TextContent(doc); text != test.text {
+			t.Errorf("TextContent for %q is %q, want %q", test.input, text, test.text)
+		}
+		if words := WordCount(doc); words != test.words {
+			t.Errorf("WordCount for %q is %d, want %d", test.input, words, test.words)
+		}
+	}
+}
+
+func TestTextContentSkipCodeBlocks(t *testing.T) {
+	tests := []struct {
+		input, text string
+	}{
+		{"foo *bar*\n\n    baz\n", "foo bar\n"},
+		{"Use `go build`:\n\n```sh\ngo build\n```\n\n- then\n\n      run\n", "Use go build:\nthen\n"},
+		{"> ```\n> quoted code\n> ```\n> quoted text\n", "quoted text\n"},
+	}
+	options := TextContentOptions{SkipCodeBlocks: true}
+	for _, test := range tests {
+		doc, err := ParseDocument([]byte(test.input))
+		if err != nil {
+			t.Fatalf("ParseDocument(%q) returned error: %s", test.input, err)
+		}
+		if text := TextContentWithOptions(doc, options); text != test.text {
+			t.Errorf("TextContentWithOptions for %q is %q, want %q", test.input, text, test.text)
+		}
+		if text, full := TextContentWithOptions(doc, TextContentOptions{}), TextContent(doc); text != full {
+			t.Errorf("TextContentWithOptions for %q with zero options is %q, but TextContent is %q", test.input, text, full)
+		}
+	}
+}
+
+func TestTextContentOfChild(t *testing.T) {
+	doc, err := ParseDocument([]byte("foo *bar*\n\n    baz\n"))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %s", err)
+	}
+	if text := TextContent(doc.FirstChild().LastChild()); text != "bar" {
+		t.Errorf("TextContent of emphasis is %q, want %q", text, "bar")
+	}
+	var prose string
+	for n := doc.FirstChild(); n != nil; n = n.Next() {
+		if n.Type != CodeBlock {
+			prose += TextContent(n)
+		}
+	}
+	if prose != "foo bar\n" {
+		t.Errorf("TextContent without code blocks is %q, want %q", prose, "foo bar\n")
+	}
+}
@@ -0,0 +1,55 @@
+package commonmark
+
+import (
+	"strings"
+)
+
+// TOCEntry is an entry in a table of contents, as returned by TableOfContents.
+type TOCEntry struct {
+	// Heading is the Heading node of the entry.
+	Heading *Node
+	// Level is the level of the heading, from 1 to 6.
+	Level int
+	// Text is the plain text of the heading, without any markup.
+	Text string
+	// ID is the id of the heading; see Node.ID. It is empty unless the
+	// document was parsed with Options.HeadingIDs.
+	ID string
+	// Children are the entries for the headings that follow this one, up to
+	// the next heading of the same or a higher level.
+	Children []*TOCEntry
+}
+
+// TableOfContents returns the headings in the tree rooted at root, in document
+// order, nested according to their levels: each heading is a child of the
+// closest preceding heading of a higher level. Levels may be skipped; for
+// example, a level 3 heading directly after a level 1 heading becomes its
+// child.
+func TableOfContents(root *Node) []*TOCEntry {
+	var entries []*TOCEntry
+	// open holds the last entry at each depth of nesting.
+	var open []*TOCEntry
+	Walk(root, func(n *Node, entering bool) WalkStatus {
+		if !entering || n.Type != Heading {
+			return WalkContinue
+		}
+		entry := &TOCEntry{
+			Heading: n,
+			Level:   n.level,
+			Text:    strings.TrimSuffix(TextContent(n), "\n"),
+			ID:      n.id,
+		}
+		for len(open) > 0 && open[len(open)-1].Level >= entry.Level {
+			open = open[:len(open)-1]
+		}
+		if len(open) == 0 {
+			entries = append(entries, entry)
+		} else {
+			parent := open[len(open)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		open = append(open, entry)
+		return WalkSkipChildren
+	})
+	return entries
+}
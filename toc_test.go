@@ -0,0 +1,56 @@
+package commonmark
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// dumpTOC returns a compact representation of the entries, for use in tests.
+func dumpTOC(entries []*TOCEntry) string {
+	var buffer bytes.Buffer
+	for i, entry := range entries {
+		if i > 0 {
+			buffer.WriteString(", ")
+		}
+		fmt.Fprintf(&buffer, "%d %q #%s", entry.Level, entry.Text, entry.ID)
+		if len(entry.Children) > 0 {
+			fmt.Fprintf(&buffer, "(%s)", dumpTOC(entry.Children))
+		}
+	}
+	return buffer.String()
+}
+
+func TestTableOfContents(t *testing.T) {
+	input := "# Intro\n\ntext\n\n## *Getting* `started`\n\n### Install\n\n### Configure\n\n" +
+		"## Usage\n\n> #### Quoted\n\n# API\n\n### Skipped a level\n\n## Back\n\nSetext\n------\n"
+	doc, err := ParseDocumentWithOptions([]byte(input), Options{HeadingIDs: true})
+	if err != nil {
+		t.Fatalf("ParseDocumentWithOptions returned error: %s", err)
+	}
+	entries := TableOfContents(doc)
+	expected := `1 "Intro" #intro(2 "Getting started" #getting-started(3 "Install" #install, 3 "Configure" #configure), ` +
+		`2 "Usage" #usage(4 "Quoted" #quoted)), ` +
+		`1 "API" #api(3 "Skipped a level" #skipped-a-level, 2 "Back" #back, 2 "Setext" #setext)`
+	if actual := dumpTOC(entries); actual != expected {
+		t.Errorf("incorrect table of contents\nexpected: %s\nactual:   %s", expected, actual)
+	}
+	if entries[0].Heading.Type != Heading || entries[0].Heading != doc.FirstChild() {
+		t.Errorf("first entry does not refer to the first heading")
+	}
+
+	// Headings before the first top-level one are at the top level too, and
+	// ids are empty without Options.HeadingIDs.
+	doc, err = ParseDocument([]byte("### Three\n## Two\n# One\n"))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %s", err)
+	}
+	expected = `3 "Three" #, 2 "Two" #, 1 "One" #`
+	if actual := dumpTOC(TableOfContents(doc)); actual != expected {
+		t.Errorf("incorrect table of contents\nexpected: %s\nactual:   %s", expected, actual)
+	}
+
+	if entries := TableOfContents(&Node{Type: Document}); entries != nil {
+		t.Errorf("table of contents of empty document is %v, want nil", entries)
+	}
+}
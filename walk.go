@@ -0,0 +1,61 @@
+package commonmark
+
+import (
+	"fmt"
+)
+
+// WalkStatus is returned by the function passed to Walk to control the rest of
+// the traversal.
+type WalkStatus int
+
+const (
+	// WalkContinue continues the traversal normally.
+	WalkContinue WalkStatus = iota
+	// WalkSkipChildren skips the children of the node that was just entered.
+	// The node is still exited. When returned on exit, it is the same as
+	// WalkContinue.
+	WalkSkipChildren
+	// WalkStop ends the traversal immediately.
+	WalkStop
+)
+
+// Walk performs a depth-first traversal of the tree rooted at root. The
+// function fn is called with entering set to true before the children of a
+// node are visited, and with entering set to false after they have been
+// visited. This happens for all nodes, even those that cannot have children.
+//
+// The function may modify the node it is called on and its children, but not
+// the rest of the tree.
+//
+// An error is returned only if fn returns an invalid WalkStatus.
+func Walk(root *Node, fn func(n *Node, entering bool) WalkStatus) error {
+	_, err := walk(root, fn)
+	return err
+}
+
+func walk(n *Node, fn func(n *Node, entering bool) WalkStatus) (WalkStatus, error) {
+	status := fn(n, true)
+	switch status {
+	case WalkContinue:
+		for child := n.firstChild; child != nil; {
+			// Get the next sibling first, in case fn moves the child.
+			next := child.next
+			if status, err := walk(child, fn); status == WalkStop || err != nil {
+				return status, err
+			}
+			child = next
+		}
+	case WalkSkipChildren:
+	case WalkStop:
+		return status, nil
+	default:
+		return status, fmt.Errorf("commonmark: invalid WalkStatus %d", status)
+	}
+
+	switch status := fn(n, false); status {
+	case WalkContinue, WalkSkipChildren, WalkStop:
+		return status, nil
+	default:
+		return status, fmt.Errorf("commonmark: invalid WalkStatus %d", status)
+	}
+}
@@ -0,0 +1,68 @@
+package commonmark
+
+import (
+	"strings"
+	"testing"
+)
+
+// walkTrace walks the tree and returns the order in which nodes are entered
+// (+) and exited (-). The function returns the given status when entering or
+// exiting a node of the given type.
+func walkTrace(t *testing.T, root *Node, statusType NodeType, statusEntering bool, status WalkStatus) string {
+	var trace []string
+	err := Walk(root, func(n *Node, entering bool) WalkStatus {
+		if entering {
+			trace = append(trace, "+"+n.Type.String())
+		} else {
+			trace = append(trace, "-"+n.Type.String())
+		}
+		if n.Type == statusType && entering == statusEntering {
+			return status
+		}
+		return WalkContinue
+	})
+	if err != nil {
+		t.Errorf("Walk returned error: %s", err)
+	}
+	return strings.Join(trace, " ")
+}
+
+func TestWalk(t *testing.T) {
+	doc, err := ParseDocument([]byte("# *a*\n\nb\n"))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %s", err)
+	}
+	tests := []struct {
+		statusType     NodeType
+		statusEntering bool
+		status         WalkStatus
+		trace          string
+	}{
+		{Heading, true, WalkContinue,
+			"+Document +Heading +Emph +Text -Text -Emph -Heading +Paragraph +Text -Text -Paragraph -Document"},
+		{Heading, true, WalkSkipChildren,
+			"+Document +Heading -Heading +Paragraph +Text -Text -Paragraph -Document"},
+		{Heading, false, WalkSkipChildren,
+			"+Document +Heading +Emph +Text -Text -Emph -Heading +Paragraph +Text -Text -Paragraph -Document"},
+		{Emph, true, WalkStop,
+			"+Document +Heading +Emph"},
+		{Heading, false, WalkStop,
+			"+Document +Heading +Emph +Text -Text -Emph -Heading"},
+	}
+	for _, test := range tests {
+		if trace := walkTrace(t, doc, test.statusType, test.statusEntering, test.status); trace != test.trace {
+			t.Errorf("returning %d on %s (entering: %v) gave trace\n%s\nexpected\n%s",
+				test.status, test.statusType, test.statusEntering, trace, test.trace)
+		}
+	}
+}
+
+func TestWalkInvalidStatus(t *testing.T) {
+	doc, err := ParseDocument([]byte("foo\n"))
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %s", err)
+	}
+	if err := Walk(doc, func(*Node, bool) WalkStatus { return WalkStatus(42) }); err == nil {
+		t.Errorf("expected error for invalid WalkStatus")
+	}
+}